@@ -128,6 +128,27 @@ func sysCPUPath(ctx context.Context, cpu int32, relPath string) string {
 	return common.HostSysWithContext(ctx, fmt.Sprintf("devices/system/cpu/cpu%d", cpu), relPath)
 }
 
+// readSysString returns the first line of a sysfs attribute, or an empty
+// string if it cannot be read.
+func readSysString(filename string) string {
+	lines, err := common.ReadLines(filename)
+	if err != nil || len(lines) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(lines[0])
+}
+
+func readSysUint(filename string) (uint64, error) {
+	lines, err := common.ReadLines(filename)
+	if err != nil {
+		return 0, err
+	}
+	if len(lines) == 0 {
+		return 0, fmt.Errorf("%s is empty", filename)
+	}
+	return strconv.ParseUint(strings.TrimSpace(lines[0]), 10, 64)
+}
+
 func finishCPUInfo(ctx context.Context, c *InfoStat) {
 	var lines []string
 	var err error
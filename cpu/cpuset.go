@@ -0,0 +1,125 @@
+//go:build darwin || linux || windows
+
+package cpu
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxCPUSetSize bounds the CPU numbers accepted by ParseCPUSet, well above
+// the largest NR_CPUS the kernel can be built with.
+const maxCPUSetSize = 1 << 16
+
+// CPUSet is a sorted set of logical CPU numbers. It is written and parsed
+// in the kernel cpulist syntax, e.g. "0-3,8-11".
+type CPUSet []int
+
+// ParseCPUSet parses a kernel cpulist such as "0-3,8-11", "0,2,4" or, with a
+// stride, "0-7:2/4". An empty string, as found in
+// /sys/devices/system/cpu/isolated when nothing is isolated, yields an empty
+// set.
+func ParseCPUSet(s string) (CPUSet, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "(null)" {
+		return CPUSet{}, nil
+	}
+
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		cpus, stride, hasStride := strings.Cut(part, ":")
+		first, last, isRange := strings.Cut(cpus, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu list %q: %w", s, err)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(last)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cpu list %q: %w", s, err)
+			}
+		}
+		if start < 0 || end < start || end >= maxCPUSetSize {
+			return nil, fmt.Errorf("invalid cpu range %q in %q", part, s)
+		}
+
+		// a range may carry a stride, "0-7:2/4" uses the first 2 CPUs of
+		// every group of 4: 0,1,4,5
+		used, group := 1, 1
+		if hasStride {
+			u, g, ok := strings.Cut(stride, "/")
+			used, err = strconv.Atoi(u)
+			if err == nil && ok {
+				group, err = strconv.Atoi(g)
+			}
+			if !isRange || !ok || err != nil || used <= 0 || group <= 0 || used > group {
+				return nil, fmt.Errorf("invalid cpu range %q in %q", part, s)
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			if (cpu-start)%group < used {
+				seen[cpu] = true
+			}
+		}
+	}
+
+	ret := make(CPUSet, 0, len(seen))
+	for cpu := range seen {
+		ret = append(ret, cpu)
+	}
+	sort.Ints(ret)
+	return ret, nil
+}
+
+// String formats the set in the kernel cpulist syntax.
+func (s CPUSet) String() string {
+	var parts []string
+	for i := 0; i < len(s); {
+		j := i
+		for j+1 < len(s) && s[j+1] == s[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(s[i]))
+		} else {
+			parts = append(parts, strconv.Itoa(s[i])+"-"+strconv.Itoa(s[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s CPUSet) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *CPUSet) UnmarshalText(text []byte) error {
+	set, err := ParseCPUSet(string(text))
+	if err != nil {
+		return err
+	}
+	*s = set
+	return nil
+}
+
+func (s CPUSet) Contains(cpu int) bool {
+	i := sort.SearchInts(s, cpu)
+	return i < len(s) && s[i] == cpu
+}
+
+// IsSubsetOf reports whether every CPU of s is also in other.
+func (s CPUSet) IsSubsetOf(other CPUSet) bool {
+	for _, cpu := range s {
+		if !other.Contains(cpu) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+//go:build linux
+
+package cpu
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// CPUSetsStat holds the CPU masks published in /sys/devices/system/cpu and
+// the scheduler affinity of the current process.
+type CPUSetsStat struct {
+	Online   CPUSet `json:"online"`
+	Offline  CPUSet `json:"offline"`
+	Present  CPUSet `json:"present"`
+	Possible CPUSet `json:"possible"`
+	Isolated CPUSet `json:"isolated"`
+	NohzFull CPUSet `json:"nohzFull"`
+	Affinity CPUSet `json:"affinity"`
+}
+
+func (c CPUSetsStat) String() string {
+	s, _ := json.Marshal(c)
+	return string(s)
+}
+
+// PinnedToIsolated reports whether the process affinity is non-empty and
+// entirely inside the isolated set.
+func (c CPUSetsStat) PinnedToIsolated() bool {
+	return len(c.Affinity) > 0 && c.Affinity.IsSubsetOf(c.Isolated)
+}
+
+func CPUSets() (*CPUSetsStat, error) {
+	return CPUSetsWithContext(context.Background())
+}
+
+func CPUSetsWithContext(ctx context.Context) (*CPUSetsStat, error) {
+	ret := &CPUSetsStat{}
+	for _, f := range []struct {
+		name string
+		set  *CPUSet
+	}{
+		{"online", &ret.Online},
+		{"offline", &ret.Offline},
+		{"present", &ret.Present},
+		{"possible", &ret.Possible},
+		{"isolated", &ret.Isolated},
+		{"nohz_full", &ret.NohzFull},
+	} {
+		set, err := readCPUSet(common.HostSysWithContext(ctx, "devices/system/cpu", f.name))
+		if err != nil {
+			return nil, err
+		}
+		*f.set = set
+	}
+
+	affinity, err := Affinity(0)
+	if err != nil {
+		return nil, err
+	}
+	ret.Affinity = affinity
+	return ret, nil
+}
+
+// Affinity returns the CPUs pid is allowed to run on. A pid of 0 means the
+// calling thread.
+func Affinity(pid int) (CPUSet, error) {
+	var mask unix.CPUSet
+	if err := unix.SchedGetaffinity(pid, &mask); err != nil {
+		return nil, err
+	}
+	ret := CPUSet{}
+	for cpu := 0; cpu < len(mask)*64; cpu++ {
+		if mask.IsSet(cpu) {
+			ret = append(ret, cpu)
+		}
+	}
+	return ret, nil
+}
+
+// readCPUSet parses a cpulist file. Files missing on older kernels, such
+// as isolated or nohz_full, are reported as an empty set.
+func readCPUSet(filename string) (CPUSet, error) {
+	lines, err := common.ReadLines(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return CPUSet{}, nil
+		}
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return CPUSet{}, nil
+	}
+	return ParseCPUSet(lines[0])
+}
@@ -0,0 +1,60 @@
+//go:build darwin || linux || windows
+
+package cpu
+
+import (
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func TestParseCPUSet(t *testing.T) {
+	tests := []struct {
+		in  string
+		exp string
+	}{
+		{"", ""},
+		{"(null)", ""},
+		{"0", "0"},
+		{"0-3,8-11", "0-3,8-11"},
+		{"0,2,4", "0,2,4"},
+		{"3,1,2,1", "1-3"},
+		{" 0-1 ,\n", "0-1"},
+		{"0-7:2/4", "0-1,4-5"},
+		{"0-7:1/2", "0,2,4,6"},
+		{"4-11:4/4", "4-11"},
+		{"0-9:3/5,2", "0-2,5-7"},
+	}
+	for _, tt := range tests {
+		set, err := ParseCPUSet(tt.in)
+		assert.Nil(t, err, tt.in)
+		assert.Equal(t, tt.exp, set.String(), tt.in)
+	}
+}
+
+func TestParseCPUSetInvalid(t *testing.T) {
+	for _, in := range []string{
+		"a",
+		"3-1",
+		"-1",
+		"0-",
+		"0-7:2",
+		"0-7:5/4",
+		"0-7:0/4",
+		"0-7:2/0",
+		"3:1/2",
+		"0-2147483647",
+		"65536",
+	} {
+		_, err := ParseCPUSet(in)
+		assert.NotNil(t, err, in)
+	}
+}
+
+func TestCPUSetSubset(t *testing.T) {
+	isolated, _ := ParseCPUSet("2-3,6-7")
+	assert.Equal(t, true, CPUSet{2, 7}.IsSubsetOf(isolated))
+	assert.Equal(t, false, CPUSet{2, 4}.IsSubsetOf(isolated))
+	assert.Equal(t, true, isolated.Contains(6))
+	assert.Equal(t, false, isolated.Contains(5))
+}
@@ -0,0 +1,244 @@
+//go:build darwin || linux || windows
+
+package cpu
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Feature is a CPU capability in a vocabulary shared by x86 flags and ARM
+// Features. Names follow /proc/cpuinfo on x86 where one exists; flags that
+// have no entry below are kept verbatim, lower-cased.
+type Feature string
+
+const (
+	// x86
+	FeatureX86_64     Feature = "lm"
+	FeatureCMOV       Feature = "cmov"
+	FeatureCX8        Feature = "cx8"
+	FeatureCX16       Feature = "cx16"
+	FeatureFPU        Feature = "fpu"
+	FeatureFXSR       Feature = "fxsr"
+	FeatureMMX        Feature = "mmx"
+	FeatureLAHF       Feature = "lahf_lm"
+	FeaturePOPCNT     Feature = "popcnt"
+	FeatureSSE        Feature = "sse"
+	FeatureSSE2       Feature = "sse2"
+	FeatureSSE3       Feature = "sse3"
+	FeatureSSSE3      Feature = "ssse3"
+	FeatureSSE41      Feature = "sse4_1"
+	FeatureSSE42      Feature = "sse4_2"
+	FeatureAVX        Feature = "avx"
+	FeatureAVX2       Feature = "avx2"
+	FeatureFMA        Feature = "fma"
+	FeatureF16C       Feature = "f16c"
+	FeatureBMI1       Feature = "bmi1"
+	FeatureBMI2       Feature = "bmi2"
+	FeatureLZCNT      Feature = "lzcnt"
+	FeatureMOVBE      Feature = "movbe"
+	FeatureXSAVE      Feature = "xsave"
+	FeatureADX        Feature = "adx"
+	FeatureRDSEED     Feature = "rdseed"
+	FeatureERMS       Feature = "erms"
+	FeatureVAES       Feature = "vaes"
+	FeatureVPCLMUL    Feature = "vpclmulqdq"
+	FeatureGFNI       Feature = "gfni"
+	FeatureAVXVNNI    Feature = "avx_vnni"
+	FeatureAMXTile    Feature = "amx_tile"
+	FeatureAMXBF16    Feature = "amx_bf16"
+	FeatureAMXInt8    Feature = "amx_int8"
+	FeatureHypervisor Feature = "hypervisor"
+
+	// AVX-512 subsets, named without the underscore cpuinfo uses for some
+	FeatureAVX512F         Feature = "avx512f"
+	FeatureAVX512CD        Feature = "avx512cd"
+	FeatureAVX512BW        Feature = "avx512bw"
+	FeatureAVX512DQ        Feature = "avx512dq"
+	FeatureAVX512VL        Feature = "avx512vl"
+	FeatureAVX512IFMA      Feature = "avx512ifma"
+	FeatureAVX512VBMI      Feature = "avx512vbmi"
+	FeatureAVX512VBMI2     Feature = "avx512vbmi2"
+	FeatureAVX512VNNI      Feature = "avx512vnni"
+	FeatureAVX512BITALG    Feature = "avx512bitalg"
+	FeatureAVX512VPOPCNTDQ Feature = "avx512vpopcntdq"
+	FeatureAVX512BF16      Feature = "avx512bf16"
+	FeatureAVX512FP16      Feature = "avx512fp16"
+
+	// ARM
+	FeatureNEON    Feature = "neon"
+	FeatureFP16    Feature = "fp16"
+	FeatureDotProd Feature = "dotprod"
+	FeatureAtomics Feature = "atomics"
+	FeatureSVE     Feature = "sve"
+	FeatureSVE2    Feature = "sve2"
+	FeatureSME     Feature = "sme"
+	FeatureI8MM    Feature = "i8mm"
+	FeatureBF16    Feature = "bf16"
+
+	// shared by x86 and ARM
+	FeatureAES    Feature = "aes"
+	FeatureCLMUL  Feature = "clmul"
+	FeatureSHA1   Feature = "sha1"
+	FeatureSHA2   Feature = "sha2"
+	FeatureSHA3   Feature = "sha3"
+	FeatureSHA512 Feature = "sha512"
+	FeatureCRC32  Feature = "crc32"
+	FeatureRNG    Feature = "rng"
+)
+
+// featureAliases maps raw flags from /proc/cpuinfo (x86 and ARM) and
+// machdep.cpu sysctls (darwin) to the shared vocabulary.
+var featureAliases = map[string][]Feature{
+	"pni":       {FeatureSSE3},
+	"sse4.1":    {FeatureSSE41},
+	"sse4.2":    {FeatureSSE42, FeatureCRC32},
+	"sse4_2":    {FeatureSSE42, FeatureCRC32},
+	"avx1.0":    {FeatureAVX},
+	"em64t":     {FeatureX86_64},
+	"lahf":      {FeatureLAHF},
+	"abm":       {FeatureLZCNT},
+	"pclmulqdq": {FeatureCLMUL},
+	"sha_ni":    {FeatureSHA1, FeatureSHA2},
+	"rdrand":    {FeatureRNG},
+	"rdrnd":     {FeatureRNG},
+	"fma3":      {FeatureFMA},
+
+	"asimd":   {FeatureNEON},
+	"pmull":   {FeatureCLMUL},
+	"fphp":    {FeatureFP16},
+	"asimdhp": {FeatureFP16},
+	"asimddp": {FeatureDotProd},
+	"svei8mm": {FeatureI8MM},
+	"svebf16": {FeatureBF16},
+	"vfpv4":   {"vfpv4", FeatureFMA},
+}
+
+// x86-64 microarchitecture levels as defined by the x86-64 psABI.
+var x86Levels = [][]Feature{
+	{FeatureCMOV, FeatureCX8, FeatureFPU, FeatureFXSR, FeatureMMX, FeatureSSE, FeatureSSE2},
+	{FeatureCX16, FeatureLAHF, FeaturePOPCNT, FeatureSSE3, FeatureSSE41, FeatureSSE42, FeatureSSSE3},
+	{FeatureAVX, FeatureAVX2, FeatureBMI1, FeatureBMI2, FeatureF16C, FeatureFMA, FeatureLZCNT, FeatureMOVBE, FeatureXSAVE},
+	{FeatureAVX512F, FeatureAVX512BW, FeatureAVX512CD, FeatureAVX512DQ, FeatureAVX512VL},
+}
+
+// FeatureSet is a normalized set of CPU features. It is marshalled to JSON
+// as a sorted list.
+type FeatureSet map[Feature]struct{}
+
+// ParseFeatures normalizes raw flags, such as InfoStat.Flags, into a
+// FeatureSet.
+func ParseFeatures(flags []string) FeatureSet {
+	ret := make(FeatureSet, len(flags))
+	for _, flag := range flags {
+		flag = strings.ToLower(strings.TrimSpace(flag))
+		if flag == "" {
+			continue
+		}
+		if aliases, ok := featureAliases[flag]; ok {
+			for _, f := range aliases {
+				ret[f] = struct{}{}
+			}
+			continue
+		}
+		if strings.HasPrefix(flag, "avx512_") {
+			flag = "avx512" + strings.TrimPrefix(flag, "avx512_")
+		}
+		ret[Feature(flag)] = struct{}{}
+	}
+	return ret
+}
+
+// Features returns the normalized feature set of c.
+func (c InfoStat) Features() FeatureSet {
+	return ParseFeatures(c.Flags)
+}
+
+// Features returns the features available on every CPU of the host, so that
+// a code path chosen from it is safe wherever the process is scheduled.
+func Features() (FeatureSet, error) {
+	return FeaturesWithContext(context.Background())
+}
+
+func FeaturesWithContext(ctx context.Context) (FeatureSet, error) {
+	infos, err := InfoWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ret FeatureSet
+	for _, info := range infos {
+		features := info.Features()
+		if ret == nil {
+			ret = features
+			continue
+		}
+		for f := range ret {
+			if !features.Has(f) {
+				delete(ret, f)
+			}
+		}
+	}
+	if ret == nil {
+		ret = FeatureSet{}
+	}
+	return ret, nil
+}
+
+func (s FeatureSet) Has(f Feature) bool {
+	_, ok := s[f]
+	return ok
+}
+
+// HasAll reports whether every feature in fs is present.
+func (s FeatureSet) HasAll(fs ...Feature) bool {
+	for _, f := range fs {
+		if !s.Has(f) {
+			return false
+		}
+	}
+	return true
+}
+
+// List returns the features in alphabetical order.
+func (s FeatureSet) List() []Feature {
+	ret := make([]Feature, 0, len(s))
+	for f := range s {
+		ret = append(ret, f)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
+
+// X86Level returns the x86-64 microarchitecture level (1 to 4) supported by
+// the set, or 0 when it does not describe an x86-64 CPU.
+func (s FeatureSet) X86Level() int {
+	if !s.Has(FeatureX86_64) {
+		return 0
+	}
+	level := 0
+	for _, required := range x86Levels {
+		if !s.HasAll(required...) {
+			break
+		}
+		level++
+	}
+	return level
+}
+
+func (s FeatureSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.List())
+}
+
+func (s *FeatureSet) UnmarshalJSON(data []byte) error {
+	var list []Feature
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*s = make(FeatureSet, len(list))
+	for _, f := range list {
+		(*s)[f] = struct{}{}
+	}
+	return nil
+}
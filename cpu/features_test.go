@@ -0,0 +1,63 @@
+//go:build darwin || linux || windows
+
+package cpu
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+const (
+	// flags of a Skylake-SP without avx512 aliases
+	flagsX86V4 = "fpu vme de pse tsc msr pae mce cx8 apic sep mtrr pge mca cmov pat pse36 clflush mmx fxsr sse sse2 ss ht syscall nx pdpe1gb rdtscp lm constant_tsc rep_good nopl xtopology nonstop_tsc cpuid tsc_known_freq pni pclmulqdq ssse3 fma cx16 pcid sse4_1 sse4_2 x2apic movbe popcnt aes xsave avx f16c rdrand hypervisor lahf_lm abm 3dnowprefetch fsgsbase bmi1 hle avx2 smep bmi2 erms invpcid rtm avx512f avx512dq rdseed adx smap clflushopt clwb avx512cd avx512bw avx512vl xsaveopt xsavec xgetbv1 xsaves arat avx512_vnni"
+	// flags of a Nehalem
+	flagsX86V2 = "fpu vme de pse tsc msr pae mce cx8 apic sep mtrr pge mca cmov pat pse36 clflush mmx fxsr sse sse2 ss syscall nx lm constant_tsc pni ssse3 cx16 sse4_1 sse4_2 popcnt lahf_lm"
+	// Features of a Neoverse N1
+	flagsARM = "fp asimd evtstrm aes pmull sha1 sha2 crc32 atomics fphp asimdhp cpuid asimdrdm lrcpc dcpop asimddp ssbs"
+)
+
+func TestParseFeatures(t *testing.T) {
+	x86 := ParseFeatures(strings.Fields(flagsX86V4))
+	assert.Equal(t, true, x86.HasAll(FeatureSSE3, FeatureCLMUL, FeatureRNG, FeatureLZCNT, FeatureCRC32, FeatureAVX512VNNI))
+	assert.Equal(t, false, x86.Has("pni"))
+	assert.Equal(t, false, x86.Has("avx512_vnni"))
+	assert.Equal(t, true, x86.Has(FeatureHypervisor))
+
+	arm := ParseFeatures(strings.Fields(flagsARM))
+	assert.Equal(t, true, arm.HasAll(FeatureNEON, FeatureCLMUL, FeatureFP16, FeatureDotProd, FeatureAES, FeatureSHA2, FeatureCRC32, FeatureAtomics))
+	assert.Equal(t, false, arm.Has(FeatureSVE))
+
+	assert.Equal(t, 0, len(ParseFeatures([]string{"", " "})))
+	assert.Equal(t, true, ParseFeatures([]string{" AVX2 "}).Has(FeatureAVX2))
+}
+
+func TestFeatureSetX86Level(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags string
+		exp   int
+	}{
+		{"skylake-sp", flagsX86V4, 4},
+		{"nehalem", flagsX86V2, 2},
+		{"baseline", "lm cmov cx8 fpu fxsr mmx sse sse2", 1},
+		{"32-bit", "cmov cx8 fpu fxsr mmx sse sse2", 0},
+		{"arm", flagsARM, 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.exp, ParseFeatures(strings.Fields(tt.flags)).X86Level(), tt.name)
+	}
+}
+
+func TestFeatureSetJSON(t *testing.T) {
+	set := ParseFeatures([]string{"sse2", "aes", "avx"})
+	data, err := json.Marshal(set)
+	assert.Nil(t, err)
+	assert.Equal(t, `["aes","avx","sse2"]`, string(data))
+
+	var got FeatureSet
+	assert.Nil(t, json.Unmarshal(data, &got))
+	assert.DeepEqual(t, set, got)
+}
@@ -0,0 +1,123 @@
+//go:build linux
+
+package cpu
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// FreqStat is the live cpufreq state of a single logical CPU.
+//
+// CurMhz is the clock the kernel last observed, unlike InfoStat.Mhz which
+// always reports cpuinfo_max_freq. MinMhz and MaxMhz are the limits the
+// governor is currently allowed to use, HwMinMhz and HwMaxMhz are the
+// hardware limits.
+type FreqStat struct {
+	CPU                         int32    `json:"cpu"`
+	Policy                      string   `json:"policy"`
+	Driver                      string   `json:"driver,omitempty"`
+	CurMhz                      float64  `json:"curMhz"`
+	MinMhz                      float64  `json:"minMhz"`
+	MaxMhz                      float64  `json:"maxMhz"`
+	HwMinMhz                    float64  `json:"hwMinMhz,omitempty"`
+	HwMaxMhz                    float64  `json:"hwMaxMhz,omitempty"`
+	Governor                    string   `json:"governor,omitempty"`
+	AvailableGovernors          []string `json:"availableGovernors,omitempty"`
+	EnergyPerformancePreference string   `json:"energyPerformancePreference,omitempty"`
+	BoostSupported              bool     `json:"boostSupported"`
+	Boost                       bool     `json:"boost"`
+}
+
+func (f FreqStat) String() string {
+	s, _ := json.Marshal(f)
+	return string(s)
+}
+
+// Frequency returns the cpufreq state of every CPU, sorted by CPU number.
+// Only the files below /sys/devices/system/cpu/cpufreq are read, once per
+// policy, so it is cheap enough to be sampled frequently.
+func Frequency() ([]FreqStat, error) {
+	return FrequencyWithContext(context.Background())
+}
+
+func FrequencyWithContext(ctx context.Context) ([]FreqStat, error) {
+	policies, err := filepath.Glob(common.HostSysWithContext(ctx, "devices/system/cpu/cpufreq/policy[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+
+	globalBoostSupported, globalBoost := readGlobalBoost(ctx)
+
+	var ret []FreqStat
+	for _, policy := range policies {
+		f := FreqStat{
+			Policy:                      filepath.Base(policy),
+			Driver:                      readSysString(filepath.Join(policy, "scaling_driver")),
+			CurMhz:                      readFreqMhz(filepath.Join(policy, "scaling_cur_freq")),
+			MinMhz:                      readFreqMhz(filepath.Join(policy, "scaling_min_freq")),
+			MaxMhz:                      readFreqMhz(filepath.Join(policy, "scaling_max_freq")),
+			HwMinMhz:                    readFreqMhz(filepath.Join(policy, "cpuinfo_min_freq")),
+			HwMaxMhz:                    readFreqMhz(filepath.Join(policy, "cpuinfo_max_freq")),
+			Governor:                    readSysString(filepath.Join(policy, "scaling_governor")),
+			AvailableGovernors:          strings.Fields(readSysString(filepath.Join(policy, "scaling_available_governors"))),
+			EnergyPerformancePreference: readSysString(filepath.Join(policy, "energy_performance_preference")),
+			BoostSupported:              globalBoostSupported,
+			Boost:                       globalBoost,
+		}
+		// newer kernels expose boost per policy, which takes precedence
+		if v, err := readSysUint(filepath.Join(policy, "boost")); err == nil {
+			f.BoostSupported = true
+			f.Boost = v != 0
+		}
+
+		cpus := strings.Fields(readSysString(filepath.Join(policy, "affected_cpus")))
+		if len(cpus) == 0 {
+			// policyN is named after the first CPU it manages
+			cpus = []string{strings.TrimPrefix(f.Policy, "policy")}
+		}
+		for _, c := range cpus {
+			n, err := strconv.ParseInt(c, 10, 32)
+			if err != nil {
+				continue
+			}
+			f.CPU = int32(n)
+			ret = append(ret, f)
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].CPU < ret[j].CPU })
+	return ret, nil
+}
+
+// readGlobalBoost reports the system wide boost state, either from the
+// acpi-cpufreq style cpufreq/boost or from intel_pstate/no_turbo.
+func readGlobalBoost(ctx context.Context) (supported, enabled bool) {
+	if v, err := readSysUint(common.HostSysWithContext(ctx, "devices/system/cpu/cpufreq/boost")); err == nil {
+		return true, v != 0
+	}
+	if v, err := readSysUint(common.HostSysWithContext(ctx, "devices/system/cpu/intel_pstate/no_turbo")); err == nil {
+		return true, v == 0
+	}
+	return false, false
+}
+
+// readFreqMhz reads a cpufreq file and converts its value to MHz. Missing or
+// malformed files are reported as 0.
+func readFreqMhz(filename string) float64 {
+	v, err := readSysUint(filename)
+	if err != nil {
+		return 0
+	}
+	mhz := float64(v) / 1000.0 // value is in kHz
+	if mhz > 9999 {
+		mhz /= 1000.0 // value in Hz
+	}
+	return mhz
+}
@@ -0,0 +1,116 @@
+//go:build linux
+
+package cpu
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(filename), 0o755))
+		assert.Nil(t, os.WriteFile(filename, []byte(content), 0o644))
+	}
+}
+
+func TestReadFreqMhz(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"khz":     "2400000\n",
+		"hz":      "3600000000\n",
+		"invalid": "n/a\n",
+		"empty":   "",
+	})
+	for _, tt := range []struct {
+		file string
+		want float64
+	}{
+		{"khz", 2400},
+		{"hz", 3600},
+		{"invalid", 0},
+		{"empty", 0},
+		{"missing", 0},
+	} {
+		assert.Equal(t, tt.want, readFreqMhz(filepath.Join(dir, tt.file)))
+	}
+}
+
+func TestFrequency(t *testing.T) {
+	sys := t.TempDir()
+	t.Setenv("HOST_SYS", sys)
+	writeTestFiles(t, sys, map[string]string{
+		// intel_pstate with turbo disabled, one policy per CPU
+		"devices/system/cpu/intel_pstate/no_turbo":                         "1\n",
+		"devices/system/cpu/cpufreq/policy0/scaling_driver":                "intel_pstate\n",
+		"devices/system/cpu/cpufreq/policy0/scaling_cur_freq":              "1200000\n",
+		"devices/system/cpu/cpufreq/policy0/scaling_min_freq":              "800000\n",
+		"devices/system/cpu/cpufreq/policy0/scaling_max_freq":              "4200000\n",
+		"devices/system/cpu/cpufreq/policy0/cpuinfo_min_freq":              "400000\n",
+		"devices/system/cpu/cpufreq/policy0/cpuinfo_max_freq":              "4700000\n",
+		"devices/system/cpu/cpufreq/policy0/scaling_governor":              "powersave\n",
+		"devices/system/cpu/cpufreq/policy0/scaling_available_governors":   "performance powersave\n",
+		"devices/system/cpu/cpufreq/policy0/energy_performance_preference": "balance_power\n",
+		"devices/system/cpu/cpufreq/policy2/scaling_cur_freq":              "2000000\n",
+		"devices/system/cpu/cpufreq/policy2/affected_cpus":                 "2 3\n",
+		"devices/system/cpu/cpufreq/policy2/boost":                         "1\n",
+		"devices/system/cpu/cpufreq/policy2/scaling_available_governors":   "\n",
+	})
+
+	stats, err := Frequency()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(stats))
+
+	// no affected_cpus, the CPU is taken from the policy name
+	f := stats[0]
+	assert.Equal(t, int32(0), f.CPU)
+	assert.Equal(t, "policy0", f.Policy)
+	assert.Equal(t, "intel_pstate", f.Driver)
+	assert.Equal(t, 1200.0, f.CurMhz)
+	assert.Equal(t, 800.0, f.MinMhz)
+	assert.Equal(t, 4200.0, f.MaxMhz)
+	assert.Equal(t, 400.0, f.HwMinMhz)
+	assert.Equal(t, 4700.0, f.HwMaxMhz)
+	assert.Equal(t, "powersave", f.Governor)
+	assert.DeepEqual(t, []string{"performance", "powersave"}, f.AvailableGovernors)
+	assert.Equal(t, "balance_power", f.EnergyPerformancePreference)
+	assert.Equal(t, true, f.BoostSupported)
+	assert.Equal(t, false, f.Boost)
+
+	// the per policy boost takes precedence over no_turbo
+	for i, cpu := range []int32{2, 3} {
+		f := stats[i+1]
+		assert.Equal(t, cpu, f.CPU)
+		assert.Equal(t, "policy2", f.Policy)
+		assert.Equal(t, 2000.0, f.CurMhz)
+		assert.Equal(t, true, f.Boost)
+		assert.Equal(t, 0, len(f.AvailableGovernors))
+	}
+}
+
+func TestReadGlobalBoost(t *testing.T) {
+	sys := t.TempDir()
+	t.Setenv("HOST_SYS", sys)
+	ctx := context.Background()
+
+	supported, enabled := readGlobalBoost(ctx)
+	assert.Equal(t, false, supported)
+	assert.Equal(t, false, enabled)
+
+	writeTestFiles(t, sys, map[string]string{"devices/system/cpu/intel_pstate/no_turbo": "0\n"})
+	supported, enabled = readGlobalBoost(ctx)
+	assert.Equal(t, true, supported)
+	assert.Equal(t, true, enabled)
+
+	// acpi-cpufreq is preferred over intel_pstate
+	writeTestFiles(t, sys, map[string]string{"devices/system/cpu/cpufreq/boost": "0\n"})
+	supported, enabled = readGlobalBoost(ctx)
+	assert.Equal(t, true, supported)
+	assert.Equal(t, false, enabled)
+}
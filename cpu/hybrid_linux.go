@@ -0,0 +1,208 @@
+//go:build linux
+
+package cpu
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+type CoreType string
+
+const (
+	CoreTypePerformance CoreType = "performance"
+	CoreTypeMid         CoreType = "mid"
+	CoreTypeEfficiency  CoreType = "efficiency"
+	// CoreTypeUniform is used on systems where every core is the same.
+	CoreTypeUniform CoreType = "uniform"
+)
+
+// CoreClassStat is the class of a logical CPU. Capacity is the normalized
+// cpu_capacity reported by the kernel (1024 for the fastest core), or 0
+// when the kernel does not publish it.
+type CoreClassStat struct {
+	CPU      int32    `json:"cpu"`
+	Type     CoreType `json:"type"`
+	Capacity uint64   `json:"capacity,omitempty"`
+}
+
+// HybridStat describes how the CPUs of a hybrid (Intel P-core/E-core or ARM
+// big.LITTLE) system are split between core types.
+type HybridStat struct {
+	Hybrid bool                `json:"hybrid"`
+	CPUs   []CoreClassStat     `json:"cpus"`
+	Counts map[CoreType]int    `json:"counts"`
+	Sets   map[CoreType]CPUSet `json:"sets"`
+}
+
+func (h HybridStat) String() string {
+	s, _ := json.Marshal(h)
+	return string(s)
+}
+
+// TypesOf counts the CPUs of set by core type, e.g. to check on which kind
+// of core a process with a given affinity may run.
+func (h HybridStat) TypesOf(set CPUSet) map[CoreType]int {
+	ret := make(map[CoreType]int)
+	for _, c := range h.CPUs {
+		if set.Contains(int(c.CPU)) {
+			ret[c.Type]++
+		}
+	}
+	return ret
+}
+
+// Hybrid classifies every CPU by core type. Intel hybrid parts are detected
+// through the cpu_core and cpu_atom PMUs, other systems through the
+// per-CPU cpu_capacity.
+func Hybrid() (*HybridStat, error) {
+	return HybridWithContext(context.Background())
+}
+
+func HybridWithContext(ctx context.Context) (*HybridStat, error) {
+	cpuDirs, err := filepath.Glob(common.HostSysWithContext(ctx, "devices/system/cpu/cpu[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &HybridStat{
+		Counts: make(map[CoreType]int),
+		Sets:   make(map[CoreType]CPUSet),
+	}
+	for _, cpuDir := range cpuDirs {
+		n, err := strconv.ParseInt(strings.TrimPrefix(filepath.Base(cpuDir), "cpu"), 10, 32)
+		if err != nil {
+			continue
+		}
+		c := CoreClassStat{CPU: int32(n), Type: CoreTypeUniform}
+		c.Capacity, _ = readSysUint(filepath.Join(cpuDir, "cpu_capacity"))
+		ret.CPUs = append(ret.CPUs, c)
+	}
+	sort.Slice(ret.CPUs, func(i, j int) bool { return ret.CPUs[i].CPU < ret.CPUs[j].CPU })
+
+	pCores, _ := readCPUSet(common.HostSysWithContext(ctx, "devices/cpu_core/cpus"))
+	eCores, _ := readCPUSet(common.HostSysWithContext(ctx, "devices/cpu_atom/cpus"))
+	if len(pCores) > 0 && len(eCores) > 0 {
+		ret.Hybrid = true
+		for i, c := range ret.CPUs {
+			switch {
+			case pCores.Contains(int(c.CPU)):
+				ret.CPUs[i].Type = CoreTypePerformance
+			case eCores.Contains(int(c.CPU)):
+				ret.CPUs[i].Type = CoreTypeEfficiency
+			}
+		}
+	} else {
+		classifyByCapacity(ret)
+	}
+
+	for _, c := range ret.CPUs {
+		ret.Counts[c.Type]++
+		ret.Sets[c.Type] = append(ret.Sets[c.Type], int(c.CPU))
+	}
+	return ret, nil
+}
+
+// classifyByCapacity marks the CPUs with the highest capacity as performance
+// cores, those with the lowest as efficiency cores and anything in between,
+// as found on tri-cluster ARM SoCs, as mid cores.
+func classifyByCapacity(h *HybridStat) {
+	var lowest, highest uint64
+	for _, c := range h.CPUs {
+		if c.Capacity == 0 {
+			return // capacity is unknown for part of the system
+		}
+		if lowest == 0 || c.Capacity < lowest {
+			lowest = c.Capacity
+		}
+		if c.Capacity > highest {
+			highest = c.Capacity
+		}
+	}
+	if lowest == highest {
+		return
+	}
+
+	h.Hybrid = true
+	for i, c := range h.CPUs {
+		switch c.Capacity {
+		case highest:
+			h.CPUs[i].Type = CoreTypePerformance
+		case lowest:
+			h.CPUs[i].Type = CoreTypeEfficiency
+		default:
+			h.CPUs[i].Type = CoreTypeMid
+		}
+	}
+}
+
+// PercentByCoreType calculates the percentage of cpu used by each core type
+// over interval.
+func PercentByCoreType(interval time.Duration) (map[CoreType]float64, error) {
+	return PercentByCoreTypeWithContext(context.Background(), interval)
+}
+
+func PercentByCoreTypeWithContext(ctx context.Context, interval time.Duration) (map[CoreType]float64, error) {
+	hybrid, err := HybridWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cpuTimes1, err := TimesWithContext(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := common.Sleep(ctx, interval); err != nil {
+		return nil, err
+	}
+
+	cpuTimes2, err := TimesWithContext(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	types := make(map[string]CoreType, len(hybrid.CPUs))
+	for _, c := range hybrid.CPUs {
+		types["cpu"+strconv.Itoa(int(c.CPU))] = c.Type
+	}
+	t1 := sumTimesByCoreType(cpuTimes1, types)
+	t2 := sumTimesByCoreType(cpuTimes2, types)
+
+	ret := make(map[CoreType]float64, len(t2))
+	for coreType, t := range t2 {
+		ret[coreType] = calculateBusy(t1[coreType], t)
+	}
+	return ret, nil
+}
+
+func sumTimesByCoreType(times []TimesStat, types map[string]CoreType) map[CoreType]TimesStat {
+	ret := make(map[CoreType]TimesStat)
+	for _, t := range times {
+		coreType, ok := types[t.CPU]
+		if !ok {
+			continue
+		}
+		sum := ret[coreType]
+		sum.CPU = string(coreType)
+		sum.User += t.User
+		sum.System += t.System
+		sum.Idle += t.Idle
+		sum.Nice += t.Nice
+		sum.Iowait += t.Iowait
+		sum.Irq += t.Irq
+		sum.Softirq += t.Softirq
+		sum.Steal += t.Steal
+		sum.Guest += t.Guest
+		sum.GuestNice += t.GuestNice
+		ret[coreType] = sum
+	}
+	return ret
+}
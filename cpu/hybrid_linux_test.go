@@ -0,0 +1,71 @@
+//go:build linux
+
+package cpu
+
+import (
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func TestClassifyByCapacity(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		capacities []uint64
+		hybrid     bool
+		types      []CoreType
+	}{
+		{"uniform", []uint64{1024, 1024}, false, []CoreType{CoreTypeUniform, CoreTypeUniform}},
+		{"unknown", []uint64{1024, 0}, false, []CoreType{CoreTypeUniform, CoreTypeUniform}},
+		{"big.LITTLE", []uint64{446, 446, 1024, 1024}, true,
+			[]CoreType{CoreTypeEfficiency, CoreTypeEfficiency, CoreTypePerformance, CoreTypePerformance}},
+		{"tri-cluster", []uint64{325, 325, 868, 1024}, true,
+			[]CoreType{CoreTypeEfficiency, CoreTypeEfficiency, CoreTypeMid, CoreTypePerformance}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &HybridStat{}
+			for i, c := range tt.capacities {
+				h.CPUs = append(h.CPUs, CoreClassStat{CPU: int32(i), Type: CoreTypeUniform, Capacity: c})
+			}
+			classifyByCapacity(h)
+			assert.Equal(t, tt.hybrid, h.Hybrid)
+			for i, c := range h.CPUs {
+				assert.Equal(t, tt.types[i], c.Type)
+			}
+		})
+	}
+}
+
+func TestHybridIntel(t *testing.T) {
+	sys := t.TempDir()
+	t.Setenv("HOST_SYS", sys)
+	writeTestFiles(t, sys, map[string]string{
+		"devices/system/cpu/cpu0/online": "1\n",
+		"devices/system/cpu/cpu1/online": "1\n",
+		"devices/system/cpu/cpu2/online": "1\n",
+		"devices/system/cpu/cpu3/online": "1\n",
+		"devices/cpu_core/cpus":          "0-1\n",
+		"devices/cpu_atom/cpus":          "2-3\n",
+	})
+
+	h, err := Hybrid()
+	assert.Nil(t, err)
+	assert.Equal(t, true, h.Hybrid)
+	assert.DeepEqual(t, map[CoreType]int{CoreTypePerformance: 2, CoreTypeEfficiency: 2}, h.Counts)
+	assert.DeepEqual(t, CPUSet{0, 1}, h.Sets[CoreTypePerformance])
+	assert.DeepEqual(t, CPUSet{2, 3}, h.Sets[CoreTypeEfficiency])
+	assert.DeepEqual(t, map[CoreType]int{CoreTypePerformance: 1, CoreTypeEfficiency: 1}, h.TypesOf(CPUSet{1, 2}))
+}
+
+func TestSumTimesByCoreType(t *testing.T) {
+	types := map[string]CoreType{"cpu0": CoreTypePerformance, "cpu1": CoreTypePerformance, "cpu2": CoreTypeEfficiency}
+	sums := sumTimesByCoreType([]TimesStat{
+		{CPU: "cpu0", User: 10, System: 5, Idle: 85, Steal: 1},
+		{CPU: "cpu1", User: 30, System: 5, Idle: 65, Iowait: 2},
+		{CPU: "cpu2", User: 1, Idle: 99},
+		{CPU: "cpu3", User: 50, Idle: 50}, // offline when classified
+	}, types)
+	assert.Equal(t, 2, len(sums))
+	assert.Equal(t, TimesStat{CPU: "performance", User: 40, System: 10, Idle: 150, Iowait: 2, Steal: 1}, sums[CoreTypePerformance])
+	assert.Equal(t, TimesStat{CPU: "efficiency", User: 1, Idle: 99}, sums[CoreTypeEfficiency])
+}
@@ -0,0 +1,191 @@
+//go:build linux
+
+package cpu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// IdleStateStat is a cpuidle state (C-state) of one CPU as found in
+// /sys/devices/system/cpu/cpuN/cpuidle/stateX. Latency and Time are in
+// microseconds, Usage is the number of times the state was entered.
+type IdleStateStat struct {
+	State    int    `json:"state"`
+	Name     string `json:"name"`
+	Desc     string `json:"desc,omitempty"`
+	Latency  uint64 `json:"latency"`
+	Usage    uint64 `json:"usage"`
+	Time     uint64 `json:"time"`
+	Disabled bool   `json:"disabled"`
+}
+
+// IdleStat holds the cpuidle states of one CPU, sorted by state number.
+type IdleStat struct {
+	CPU    int32           `json:"cpu"`
+	States []IdleStateStat `json:"states"`
+}
+
+// IdleResidencyStat is the time a CPU spent in a C-state during an interval.
+type IdleResidencyStat struct {
+	State    int     `json:"state"`
+	Name     string  `json:"name"`
+	Latency  uint64  `json:"latency"`
+	Disabled bool    `json:"disabled"`
+	Usage    uint64  `json:"usage"`
+	Time     uint64  `json:"time"`
+	Percent  float64 `json:"percent"`
+}
+
+// IdleResidency is the time one CPU spent in each of its C-states during an
+// interval. States present in only one of the samples are left out.
+type IdleResidency struct {
+	CPU    int32               `json:"cpu"`
+	States []IdleResidencyStat `json:"states"`
+}
+
+func (i IdleStat) String() string {
+	s, _ := json.Marshal(i)
+	return string(s)
+}
+
+func (i IdleResidency) String() string {
+	s, _ := json.Marshal(i)
+	return string(s)
+}
+
+// DeepStatesDisabled reports whether every C-state whose exit latency is
+// above maxLatency microseconds is disabled on this CPU.
+func (i IdleStat) DeepStatesDisabled(maxLatency uint64) bool {
+	for _, s := range i.States {
+		if s.Latency > maxLatency && !s.Disabled {
+			return false
+		}
+	}
+	return true
+}
+
+// Idle returns the cpuidle states of every CPU, sorted by CPU number. CPUs
+// without a cpuidle directory, e.g. when cpuidle is disabled, are omitted.
+func Idle() ([]IdleStat, error) {
+	return IdleWithContext(context.Background())
+}
+
+func IdleWithContext(ctx context.Context) ([]IdleStat, error) {
+	cpuDirs, err := filepath.Glob(common.HostSysWithContext(ctx, "devices/system/cpu/cpu[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []IdleStat
+	for _, cpuDir := range cpuDirs {
+		n, err := strconv.ParseInt(strings.TrimPrefix(filepath.Base(cpuDir), "cpu"), 10, 32)
+		if err != nil {
+			continue
+		}
+		stateDirs, err := filepath.Glob(filepath.Join(cpuDir, "cpuidle", "state[0-9]*"))
+		if err != nil || len(stateDirs) == 0 {
+			continue
+		}
+
+		stat := IdleStat{CPU: int32(n)}
+		for _, stateDir := range stateDirs {
+			idx, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(stateDir), "state"))
+			if err != nil {
+				continue
+			}
+			state := IdleStateStat{
+				State: idx,
+				Name:  readSysString(filepath.Join(stateDir, "name")),
+				Desc:  readSysString(filepath.Join(stateDir, "desc")),
+			}
+			state.Latency, _ = readSysUint(filepath.Join(stateDir, "latency"))
+			state.Usage, _ = readSysUint(filepath.Join(stateDir, "usage"))
+			state.Time, _ = readSysUint(filepath.Join(stateDir, "time"))
+			if v, err := readSysUint(filepath.Join(stateDir, "disable")); err == nil {
+				state.Disabled = v != 0
+			}
+			stat.States = append(stat.States, state)
+		}
+		sort.Slice(stat.States, func(i, j int) bool { return stat.States[i].State < stat.States[j].State })
+		ret = append(ret, stat)
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].CPU < ret[j].CPU })
+	return ret, nil
+}
+
+// IdleResidencies samples the cpuidle counters twice, interval apart, and
+// returns the time every CPU spent in each C-state in between.
+func IdleResidencies(interval time.Duration) ([]IdleResidency, error) {
+	return IdleResidenciesWithContext(context.Background(), interval)
+}
+
+func IdleResidenciesWithContext(ctx context.Context, interval time.Duration) ([]IdleResidency, error) {
+	idle1, err := IdleWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+
+	if err := common.Sleep(ctx, interval); err != nil {
+		return nil, err
+	}
+
+	idle2, err := IdleWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return calculateIdleResidencies(idle1, idle2, time.Since(start))
+}
+
+func calculateIdleResidencies(t1, t2 []IdleStat, elapsed time.Duration) ([]IdleResidency, error) {
+	if len(t1) != len(t2) {
+		return nil, fmt.Errorf(
+			"received two CPU counts: %d != %d",
+			len(t1), len(t2),
+		)
+	}
+
+	elapsedUs := float64(elapsed.Microseconds())
+	ret := make([]IdleResidency, len(t2))
+	for i, cur := range t2 {
+		if t1[i].CPU != cur.CPU {
+			return nil, fmt.Errorf("received two different CPUs: %d != %d", t1[i].CPU, cur.CPU)
+		}
+		prev := make(map[int]IdleStateStat, len(t1[i].States))
+		for _, s := range t1[i].States {
+			prev[s.State] = s
+		}
+
+		ret[i].CPU = cur.CPU
+		for _, s := range cur.States {
+			p, ok := prev[s.State]
+			if !ok || s.Time < p.Time || s.Usage < p.Usage {
+				continue
+			}
+			r := IdleResidencyStat{
+				State:    s.State,
+				Name:     s.Name,
+				Latency:  s.Latency,
+				Disabled: s.Disabled,
+				Usage:    s.Usage - p.Usage,
+				Time:     s.Time - p.Time,
+			}
+			if elapsedUs > 0 {
+				r.Percent = float64(r.Time) / elapsedUs * 100
+			}
+			ret[i].States = append(ret[i].States, r)
+		}
+	}
+	return ret, nil
+}
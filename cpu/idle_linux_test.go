@@ -0,0 +1,57 @@
+//go:build linux
+
+package cpu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func TestCalculateIdleResidencies(t *testing.T) {
+	t1 := []IdleStat{
+		{CPU: 0, States: []IdleStateStat{
+			{State: 0, Name: "POLL", Usage: 10, Time: 100},
+			{State: 1, Name: "C1", Latency: 2, Usage: 100, Time: 10000},
+			{State: 2, Name: "C6", Latency: 170, Usage: 50, Time: 50000},
+		}},
+		{CPU: 2, States: []IdleStateStat{
+			{State: 1, Name: "C1", Usage: 100, Time: 10000},
+		}},
+	}
+	t2 := []IdleStat{
+		{CPU: 0, States: []IdleStateStat{
+			{State: 0, Name: "POLL", Usage: 10, Time: 100},
+			{State: 1, Name: "C1", Latency: 2, Usage: 300, Time: 110000},
+			{State: 2, Name: "C6", Latency: 170, Usage: 80, Time: 550000, Disabled: true},
+			{State: 3, Name: "C10", Usage: 1, Time: 1},
+		}},
+		{CPU: 2, States: []IdleStateStat{
+			// reset, e.g. by CPU hotplug
+			{State: 1, Name: "C1", Usage: 5, Time: 500},
+		}},
+	}
+
+	ret, err := calculateIdleResidencies(t1, t2, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(ret))
+
+	assert.Equal(t, int32(0), ret[0].CPU)
+	assert.DeepEqual(t, []IdleResidencyStat{
+		{State: 0, Name: "POLL"},
+		{State: 1, Name: "C1", Latency: 2, Usage: 200, Time: 100000, Percent: 10},
+		{State: 2, Name: "C6", Latency: 170, Disabled: true, Usage: 30, Time: 500000, Percent: 50},
+	}, ret[0].States)
+
+	assert.Equal(t, int32(2), ret[1].CPU)
+	assert.Equal(t, 0, len(ret[1].States))
+
+	// CPU2 went offline and CPU1 came online between the samples
+	t2[1].CPU = 1
+	_, err = calculateIdleResidencies(t1, t2, time.Second)
+	assert.NotNil(t, err)
+
+	_, err = calculateIdleResidencies(t1, t2[:1], time.Second)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,217 @@
+//go:build linux
+
+package cpu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// IRQStat holds the counters of a single line of /proc/interrupts or
+// /proc/softirqs. Counts is indexed like the CPUs of the InterruptsStat it
+// belongs to.
+type IRQStat struct {
+	IRQ    string   `json:"irq"`
+	Counts []uint64 `json:"counts"`
+	Total  uint64   `json:"total"`
+
+	// Only set for /proc/interrupts. Chip and Device are filled for
+	// numbered IRQs, Description holds the whole trailing text.
+	Chip        string `json:"chip,omitempty"`
+	Device      string `json:"device,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// InterruptsStat is a sample of /proc/interrupts or /proc/softirqs.
+type InterruptsStat struct {
+	Timestamp time.Time `json:"timestamp"`
+	CPUs      []int32   `json:"cpus"`
+	IRQs      []IRQStat `json:"irqs"`
+}
+
+// IRQRate is the per second rate of an IRQ between two samples.
+//
+// TopCPU is the CPU that handled most of the interrupts in the interval and
+// TopPercent is its share of Total.
+type IRQRate struct {
+	IRQ        string    `json:"irq"`
+	Device     string    `json:"device,omitempty"`
+	PerCPU     []float64 `json:"perCpu"`
+	Total      float64   `json:"total"`
+	TopCPU     int32     `json:"topCpu"`
+	TopPercent float64   `json:"topPercent"`
+}
+
+func (i InterruptsStat) String() string {
+	s, _ := json.Marshal(i)
+	return string(s)
+}
+
+func (r IRQRate) String() string {
+	s, _ := json.Marshal(r)
+	return string(s)
+}
+
+// Imbalanced reports whether a single CPU handled more than threshold percent
+// of the interrupts of r. IRQs that did not fire are never imbalanced.
+func (r IRQRate) Imbalanced(threshold float64) bool {
+	if r.Total <= 0 || len(r.PerCPU) < 2 {
+		return false
+	}
+	return r.TopPercent > threshold
+}
+
+// Interrupts returns the hardware interrupt counters of /proc/interrupts.
+func Interrupts() (*InterruptsStat, error) {
+	return InterruptsWithContext(context.Background())
+}
+
+func InterruptsWithContext(ctx context.Context) (*InterruptsStat, error) {
+	lines, err := common.ReadLines(common.HostProcWithContext(ctx, "interrupts"))
+	if err != nil {
+		return nil, err
+	}
+	return parseInterrupts(lines, true)
+}
+
+// SoftIRQs returns the softirq counters (NET_RX, TIMER, ...) of /proc/softirqs.
+func SoftIRQs() (*InterruptsStat, error) {
+	return SoftIRQsWithContext(context.Background())
+}
+
+func SoftIRQsWithContext(ctx context.Context) (*InterruptsStat, error) {
+	lines, err := common.ReadLines(common.HostProcWithContext(ctx, "softirqs"))
+	if err != nil {
+		return nil, err
+	}
+	return parseInterrupts(lines, false)
+}
+
+func parseInterrupts(lines []string, withDescription bool) (*InterruptsStat, error) {
+	if len(lines) == 0 {
+		return nil, errors.New("interrupts file is empty")
+	}
+
+	ret := &InterruptsStat{Timestamp: time.Now()}
+	for _, field := range strings.Fields(lines[0]) {
+		n, err := strconv.ParseInt(strings.TrimPrefix(field, "CPU"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected interrupts header %q", lines[0])
+		}
+		ret.CPUs = append(ret.CPUs, int32(n))
+	}
+
+	for _, line := range lines[1:] {
+		name, rest, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(rest)
+
+		irq := IRQStat{IRQ: strings.TrimSpace(name)}
+		i := 0
+		for ; i < len(fields) && i < len(ret.CPUs); i++ {
+			v, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				break
+			}
+			irq.Counts = append(irq.Counts, v)
+			irq.Total += v
+		}
+		if withDescription && i < len(fields) {
+			desc := fields[i:]
+			irq.Description = strings.Join(desc, " ")
+			if _, err := strconv.Atoi(irq.IRQ); err == nil {
+				irq.Chip = desc[0]
+				irq.Device = interruptDevice(desc[1:])
+			}
+		}
+		ret.IRQs = append(ret.IRQs, irq)
+	}
+	return ret, nil
+}
+
+// interruptDevice returns the device names that follow the chip of a
+// numbered IRQ. The hardware IRQ number and the trigger are skipped, in
+// both the x86 "2-edge timer" and the generic "27 Level arch_timer"
+// layouts. Before Linux 3.19 the trigger was part of the chip, as in
+// "IO-APIC-edge timer", and only the device names follow.
+func interruptDevice(desc []string) string {
+	if len(desc) > 0 {
+		hwirq, _, _ := strings.Cut(desc[0], "-")
+		if _, err := strconv.ParseUint(hwirq, 10, 64); err == nil {
+			desc = desc[1:]
+		}
+	}
+	if len(desc) > 0 && (desc[0] == "Edge" || desc[0] == "Level") {
+		desc = desc[1:]
+	}
+	return strings.Join(desc, " ")
+}
+
+// InterruptRates computes the per second rate of every IRQ present in both
+// samples. Both samples must come from the same file and CPU set.
+func InterruptRates(prev, cur *InterruptsStat) ([]IRQRate, error) {
+	if prev == nil || cur == nil {
+		return nil, errors.New("interrupt samples must not be nil")
+	}
+	// CPU hotplug may change the columns while keeping their number
+	if len(prev.CPUs) != len(cur.CPUs) {
+		return nil, fmt.Errorf(
+			"received two CPU counts: %d != %d",
+			len(prev.CPUs), len(cur.CPUs),
+		)
+	}
+	for i, c := range cur.CPUs {
+		if prev.CPUs[i] != c {
+			return nil, fmt.Errorf("column %d is CPU%d in one sample and CPU%d in the other", i, prev.CPUs[i], c)
+		}
+	}
+	elapsed := cur.Timestamp.Sub(prev.Timestamp).Seconds()
+	if elapsed <= 0 {
+		return nil, errors.New("interrupt samples must be taken at increasing times")
+	}
+
+	last := make(map[string]IRQStat, len(prev.IRQs))
+	for _, irq := range prev.IRQs {
+		last[irq.IRQ] = irq
+	}
+
+	ret := make([]IRQRate, 0, len(cur.IRQs))
+	for _, irq := range cur.IRQs {
+		p, ok := last[irq.IRQ]
+		if !ok {
+			continue
+		}
+		rate := IRQRate{
+			IRQ:    irq.IRQ,
+			Device: irq.Device,
+			PerCPU: make([]float64, len(irq.Counts)),
+			TopCPU: -1,
+		}
+		var top float64
+		for i, v := range irq.Counts {
+			if i >= len(p.Counts) || v < p.Counts[i] {
+				continue // counter reset, e.g. CPU hotplug
+			}
+			rate.PerCPU[i] = float64(v-p.Counts[i]) / elapsed
+			rate.Total += rate.PerCPU[i]
+			if rate.PerCPU[i] > top && i < len(cur.CPUs) {
+				top = rate.PerCPU[i]
+				rate.TopCPU = cur.CPUs[i]
+			}
+		}
+		if rate.Total > 0 {
+			rate.TopPercent = top / rate.Total * 100
+		}
+		ret = append(ret, rate)
+	}
+	return ret, nil
+}
@@ -0,0 +1,159 @@
+//go:build linux
+
+package cpu
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+const procInterrupts = `           CPU0       CPU1       CPU2       CPU3
+  0:         40          0          0          0   IO-APIC   2-edge      timer
+  1:          0          9          0          0   IO-APIC   1-edge      i8042
+  8:          1          0          0          0   IO-APIC-edge      rtc0
+ 24:     100000        100        200        300   PCI-MSI 524288-edge      eth0-rx-0
+NMI:          7          7          7          7   Non-maskable interrupts
+LOC:     123456     234567     345678     456789   Local timer interrupts
+ERR:          0
+MIS:          0
+`
+
+const procSoftirqs = `                    CPU0       CPU1       CPU2       CPU3
+          HI:          1          0          0          2
+       TIMER:     100000     200000     300000     400000
+      NET_RX:       5000          1          2          3
+`
+
+func TestParseInterrupts(t *testing.T) {
+	stat, err := parseInterrupts(strings.Split(procInterrupts, "\n"), true)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, []int32{0, 1, 2, 3}, stat.CPUs)
+	assert.Equal(t, 8, len(stat.IRQs))
+
+	timer := stat.IRQs[0]
+	assert.Equal(t, "0", timer.IRQ)
+	assert.Equal(t, uint64(40), timer.Total)
+	assert.Equal(t, "IO-APIC", timer.Chip)
+	assert.Equal(t, "timer", timer.Device)
+	assert.Equal(t, "IO-APIC 2-edge timer", timer.Description)
+
+	// pre 3.19 layout, chip and trigger in one field
+	rtc := stat.IRQs[2]
+	assert.Equal(t, "IO-APIC-edge", rtc.Chip)
+	assert.Equal(t, "rtc0", rtc.Device)
+
+	eth := stat.IRQs[3]
+	assert.DeepEqual(t, []uint64{100000, 100, 200, 300}, eth.Counts)
+	assert.Equal(t, "eth0-rx-0", eth.Device)
+
+	// named IRQs keep their description but have no chip
+	loc := stat.IRQs[5]
+	assert.Equal(t, "LOC", loc.IRQ)
+	assert.Equal(t, "", loc.Chip)
+	assert.Equal(t, "Local timer interrupts", loc.Description)
+	assert.Equal(t, uint64(123456+234567+345678+456789), loc.Total)
+
+	// ERR and MIS have a single counter
+	assert.DeepEqual(t, []uint64{0}, stat.IRQs[6].Counts)
+}
+
+const procInterruptsArm64 = `           CPU0       CPU1
+ 11:     123456     234567     GICv3  27 Level     arch_timer
+ 14:          0          0     GICv3  25 Level     vgic
+ 47:        360          0   ITS-MSI 524288 Edge      nvme0q0
+ 48:          0          0     GICv3  30 Level
+ 49:          5          6   ITS-MSI 1572864 Edge      eth0-tx-0, eth0-rx-0
+IPI0:      1000       2000       Rescheduling interrupts
+`
+
+func TestParseInterruptsArm64(t *testing.T) {
+	stat, err := parseInterrupts(strings.Split(procInterruptsArm64, "\n"), true)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, []int32{0, 1}, stat.CPUs)
+
+	for i, tt := range []struct {
+		chip, device string
+	}{
+		{"GICv3", "arch_timer"},
+		{"GICv3", "vgic"},
+		{"ITS-MSI", "nvme0q0"},
+		{"GICv3", ""},
+		{"ITS-MSI", "eth0-tx-0, eth0-rx-0"},
+		{"", ""},
+	} {
+		assert.Equal(t, tt.chip, stat.IRQs[i].Chip)
+		assert.Equal(t, tt.device, stat.IRQs[i].Device)
+	}
+	assert.Equal(t, "GICv3 27 Level arch_timer", stat.IRQs[0].Description)
+	assert.Equal(t, "Rescheduling interrupts", stat.IRQs[5].Description)
+}
+
+func TestParseSoftIRQs(t *testing.T) {
+	stat, err := parseInterrupts(strings.Split(procSoftirqs, "\n"), false)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(stat.IRQs))
+	assert.Equal(t, "NET_RX", stat.IRQs[2].IRQ)
+	assert.Equal(t, uint64(5006), stat.IRQs[2].Total)
+	assert.Equal(t, "", stat.IRQs[2].Description)
+}
+
+func TestParseInterruptsInvalid(t *testing.T) {
+	_, err := parseInterrupts(nil, true)
+	assert.NotNil(t, err)
+	_, err = parseInterrupts([]string{"CPU0 foo"}, true)
+	assert.NotNil(t, err)
+}
+
+func TestInterruptRates(t *testing.T) {
+	now := time.Now()
+	prev := &InterruptsStat{
+		Timestamp: now,
+		CPUs:      []int32{0, 1},
+		IRQs: []IRQStat{
+			{IRQ: "24", Device: "eth0", Counts: []uint64{1000, 1000}},
+			{IRQ: "25", Counts: []uint64{10, 10}},
+			{IRQ: "26", Counts: []uint64{500, 0}},
+		},
+	}
+	cur := &InterruptsStat{
+		Timestamp: now.Add(2 * time.Second),
+		CPUs:      []int32{0, 1},
+		IRQs: []IRQStat{
+			{IRQ: "24", Device: "eth0", Counts: []uint64{2900, 1100}},
+			{IRQ: "25", Counts: []uint64{10, 10}},
+			{IRQ: "26", Counts: []uint64{10, 20}},
+			{IRQ: "27", Counts: []uint64{10, 20}},
+		},
+	}
+	rates, err := InterruptRates(prev, cur)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(rates))
+
+	eth := rates[0]
+	assert.DeepEqual(t, []float64{950, 50}, eth.PerCPU)
+	assert.Equal(t, 1000.0, eth.Total)
+	assert.Equal(t, int32(0), eth.TopCPU)
+	assert.Equal(t, 95.0, eth.TopPercent)
+	assert.Equal(t, true, eth.Imbalanced(90))
+	assert.Equal(t, false, eth.Imbalanced(95))
+
+	idle := rates[1]
+	assert.Equal(t, int32(-1), idle.TopCPU)
+	assert.Equal(t, false, idle.Imbalanced(0))
+
+	// the counter of CPU0 went backwards and is skipped
+	reset := rates[2]
+	assert.DeepEqual(t, []float64{0, 10}, reset.PerCPU)
+	assert.Equal(t, int32(1), reset.TopCPU)
+
+	_, err = InterruptRates(cur, prev)
+	assert.NotNil(t, err)
+	_, err = InterruptRates(prev, &InterruptsStat{Timestamp: cur.Timestamp, CPUs: []int32{0}})
+	assert.NotNil(t, err)
+	// CPU1 went offline and CPU2 came online
+	_, err = InterruptRates(prev, &InterruptsStat{Timestamp: cur.Timestamp, CPUs: []int32{0, 2}})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,236 @@
+//go:build darwin || linux || windows
+
+package cpu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+	"github.com/ravoni4devs/syspector/system"
+)
+
+// stealBuckets are the upper bounds, in percent, of the steal histogram.
+var stealBuckets = []float64{1, 2, 5, 10, 20, 50, 100}
+
+// StealStat is the share of CPU time taken by the hypervisor (steal) and
+// spent running guests (guest and guest_nice) over an interval, in percent.
+// Steal is only reported on Linux.
+type StealStat struct {
+	CPU       string  `json:"cpu"`
+	Steal     float64 `json:"steal"`
+	Guest     float64 `json:"guest"`
+	GuestNice float64 `json:"guestNice"`
+}
+
+type StealBucket struct {
+	UpperBound float64 `json:"upperBound"`
+	Count      int     `json:"count"`
+}
+
+// StealHistogram is the distribution of the combined steal percent over the
+// samples kept by a StealMonitor.
+type StealHistogram struct {
+	Samples int           `json:"samples"`
+	Mean    float64       `json:"mean"`
+	Max     float64       `json:"max"`
+	Buckets []StealBucket `json:"buckets"`
+}
+
+// VirtualizationReport is the result of a StealMonitor sample.
+//
+// NoisyNeighbour is set once the monitor window is full and most of its
+// samples are above the monitor threshold, i.e. the hypervisor keeps
+// handing our CPU time to other tenants.
+type VirtualizationReport struct {
+	Virtualized    bool           `json:"virtualized"`
+	Interval       time.Duration  `json:"interval"`
+	Total          StealStat      `json:"total"`
+	CPUs           []StealStat    `json:"cpus"`
+	Histogram      StealHistogram `json:"histogram"`
+	NoisyNeighbour bool           `json:"noisyNeighbour"`
+}
+
+func (s StealStat) String() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (v VirtualizationReport) String() string {
+	s, _ := json.Marshal(v)
+	return string(s)
+}
+
+func calculateSteal(t1, t2 TimesStat) StealStat {
+	t1All, _ := getAllBusy(t1)
+	t2All, _ := getAllBusy(t2)
+	ret := StealStat{CPU: t2.CPU}
+	if t2All <= t1All {
+		return ret
+	}
+	all := t2All - t1All
+	percent := func(a, b float64) float64 {
+		return math.Min(100, math.Max(0, (b-a)/all*100))
+	}
+	ret.Steal = percent(t1.Steal, t2.Steal)
+	ret.Guest = percent(t1.Guest, t2.Guest)
+	ret.GuestNice = percent(t1.GuestNice, t2.GuestNice)
+	return ret
+}
+
+func calculateAllSteal(t1, t2 []TimesStat) ([]StealStat, error) {
+	if len(t1) != len(t2) {
+		return nil, fmt.Errorf(
+			"received two CPU counts: %d != %d",
+			len(t1), len(t2),
+		)
+	}
+
+	ret := make([]StealStat, len(t1))
+	for i, t := range t2 {
+		ret[i] = calculateSteal(t1[i], t)
+	}
+	return ret, nil
+}
+
+// StealPercent calculates the steal and guest percentages either per CPU or
+// combined over interval.
+func StealPercent(interval time.Duration, percpu bool) ([]StealStat, error) {
+	return StealPercentWithContext(context.Background(), interval, percpu)
+}
+
+func StealPercentWithContext(ctx context.Context, interval time.Duration, percpu bool) ([]StealStat, error) {
+	cpuTimes1, err := TimesWithContext(ctx, percpu)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := common.Sleep(ctx, interval); err != nil {
+		return nil, err
+	}
+
+	cpuTimes2, err := TimesWithContext(ctx, percpu)
+	if err != nil {
+		return nil, err
+	}
+
+	return calculateAllSteal(cpuTimes1, cpuTimes2)
+}
+
+// StealMonitor keeps a rolling window of steal samples to tell short steal
+// spikes from sustained noisy-neighbour contention. It is safe for
+// concurrent use.
+type StealMonitor struct {
+	mu        sync.Mutex
+	threshold float64
+	samples   *common.Window[float64]
+}
+
+// NewStealMonitor returns a monitor that keeps the last window samples and
+// flags steal above threshold percent as noisy-neighbour contention once it
+// is sustained over the window.
+func NewStealMonitor(window int, threshold float64) *StealMonitor {
+	return &StealMonitor{
+		threshold: threshold,
+		samples:   common.NewWindow[float64](window),
+	}
+}
+
+// Add records a combined steal percent, e.g. one obtained elsewhere through
+// StealPercent, and reports whether steal is now sustained.
+func (m *StealMonitor) Add(steal float64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples.Add(steal)
+	return m.samples.Sustained(func(s float64) bool { return s > m.threshold })
+}
+
+// Histogram returns the distribution of the samples in the window.
+func (m *StealMonitor) Histogram() StealHistogram {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return stealHistogram(m.samples.Samples())
+}
+
+// Sample measures steal over interval, records the combined value and
+// returns the resulting report.
+func (m *StealMonitor) Sample(interval time.Duration) (*VirtualizationReport, error) {
+	return m.SampleWithContext(context.Background(), interval)
+}
+
+func (m *StealMonitor) SampleWithContext(ctx context.Context, interval time.Duration) (*VirtualizationReport, error) {
+	total1, err := TimesWithContext(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	perCPU1, err := TimesWithContext(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+
+	if err := common.Sleep(ctx, interval); err != nil {
+		return nil, err
+	}
+
+	total2, err := TimesWithContext(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	perCPU2, err := TimesWithContext(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := calculateAllSteal(total1, total2)
+	if err != nil {
+		return nil, err
+	}
+	if len(totals) == 0 {
+		return nil, errors.New("getting steal percent: no combined CPU times")
+	}
+	cpus, err := calculateAllSteal(perCPU1, perCPU2)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &VirtualizationReport{
+		Virtualized: system.IsVirtualized(),
+		Interval:    time.Since(start),
+		Total:       totals[0],
+		CPUs:        cpus,
+	}
+	ret.NoisyNeighbour = m.Add(ret.Total.Steal)
+	ret.Histogram = m.Histogram()
+	return ret, nil
+}
+
+func stealHistogram(samples []float64) StealHistogram {
+	ret := StealHistogram{
+		Samples: len(samples),
+		Buckets: make([]StealBucket, len(stealBuckets)),
+	}
+	for i, bound := range stealBuckets {
+		ret.Buckets[i].UpperBound = bound
+	}
+	var sum float64
+	for _, s := range samples {
+		sum += s
+		ret.Max = math.Max(ret.Max, s)
+		for i, bound := range stealBuckets {
+			if s <= bound {
+				ret.Buckets[i].Count++
+				break
+			}
+		}
+	}
+	if ret.Samples > 0 {
+		ret.Mean = sum / float64(ret.Samples)
+	}
+	return ret
+}
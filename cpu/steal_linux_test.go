@@ -0,0 +1,92 @@
+//go:build linux
+
+package cpu
+
+import (
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func TestCalculateSteal(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		t1, t2 TimesStat
+		want   StealStat
+	}{
+		{
+			// user includes guest time on Linux
+			name: "guest and steal",
+			t1:   TimesStat{CPU: "cpu0", User: 100, System: 50, Idle: 800, Steal: 50},
+			t2:   TimesStat{CPU: "cpu0", User: 160, System: 60, Idle: 810, Steal: 70, Guest: 15, GuestNice: 5},
+			want: StealStat{CPU: "cpu0", Steal: 20, Guest: 15, GuestNice: 5},
+		},
+		{
+			name: "idle",
+			t1:   TimesStat{CPU: "cpu1", Idle: 100, Steal: 10},
+			t2:   TimesStat{CPU: "cpu1", Idle: 200, Steal: 10},
+			want: StealStat{CPU: "cpu1"},
+		},
+		{
+			name: "no time elapsed",
+			t1:   TimesStat{CPU: "cpu2", Idle: 100, Steal: 10},
+			t2:   TimesStat{CPU: "cpu2", Idle: 100, Steal: 10},
+			want: StealStat{CPU: "cpu2"},
+		},
+		{
+			// steal went backwards, e.g. after live migration
+			name: "counter reset",
+			t1:   TimesStat{CPU: "cpu3", Idle: 100, Steal: 50},
+			t2:   TimesStat{CPU: "cpu3", Idle: 200, Steal: 5},
+			want: StealStat{CPU: "cpu3"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, calculateSteal(tt.t1, tt.t2))
+		})
+	}
+
+	_, err := calculateAllSteal([]TimesStat{{CPU: "cpu0"}}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestStealHistogram(t *testing.T) {
+	h := stealHistogram([]float64{0, 1, 1.5, 4, 10, 30, 80, 100})
+	assert.Equal(t, 8, h.Samples)
+	assert.Equal(t, 100.0, h.Max)
+	assert.Equal(t, 28.3125, h.Mean)
+	counts := make([]int, len(h.Buckets))
+	for i, b := range h.Buckets {
+		assert.Equal(t, stealBuckets[i], b.UpperBound)
+		counts[i] = b.Count
+	}
+	// upper bounds are inclusive
+	assert.DeepEqual(t, []int{2, 1, 1, 1, 0, 1, 2}, counts)
+
+	empty := stealHistogram(nil)
+	assert.Equal(t, 0, empty.Samples)
+	assert.Equal(t, 0.0, empty.Mean)
+	assert.Equal(t, len(stealBuckets), len(empty.Buckets))
+}
+
+func TestStealMonitorSustained(t *testing.T) {
+	m := NewStealMonitor(4, 10)
+
+	// not sustained until the window is full
+	assert.Equal(t, false, m.Add(50))
+	assert.Equal(t, false, m.Add(50))
+	assert.Equal(t, false, m.Add(50))
+	assert.Equal(t, true, m.Add(5))
+
+	// 3 of 4 samples above the threshold, the ratio is reached
+	assert.Equal(t, true, m.Add(50))
+	// the oldest samples drop out of the window
+	assert.Equal(t, false, m.Add(2))
+	assert.Equal(t, false, m.Add(3))
+	// a sample at the threshold is not above it
+	assert.Equal(t, false, m.Add(10))
+
+	h := m.Histogram()
+	assert.Equal(t, 4, h.Samples)
+	assert.Equal(t, 50.0, h.Max)
+}
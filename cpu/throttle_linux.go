@@ -0,0 +1,153 @@
+//go:build linux
+
+package cpu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// ThrottleStat holds the thermal throttling counters of a CPU from
+// /sys/devices/system/cpu/cpuN/thermal_throttle. Times are in milliseconds.
+// The package counters are shared by every CPU of the same socket.
+type ThrottleStat struct {
+	CPU                  int32  `json:"cpu"`
+	CoreThrottleCount    uint64 `json:"coreThrottleCount"`
+	CoreThrottleTime     uint64 `json:"coreThrottleTime"`
+	PackageThrottleCount uint64 `json:"packageThrottleCount"`
+	PackageThrottleTime  uint64 `json:"packageThrottleTime"`
+}
+
+// ThrottleReport is the throttling that happened between two samples. CPUs
+// holds the counter deltas and Throttled is set when any of them grew.
+type ThrottleReport struct {
+	Interval  time.Duration  `json:"interval"`
+	CPUs      []ThrottleStat `json:"cpus"`
+	Throttled bool           `json:"throttled"`
+	// ThrottledCPUs lists the CPUs whose core or package counters grew.
+	ThrottledCPUs CPUSet `json:"throttledCpus"`
+}
+
+func (t ThrottleStat) String() string {
+	s, _ := json.Marshal(t)
+	return string(s)
+}
+
+func (t ThrottleReport) String() string {
+	s, _ := json.Marshal(t)
+	return string(s)
+}
+
+// Throttle returns the thermal throttling counters of every CPU, sorted by
+// CPU number. It is empty on platforms without thermal_throttle support
+// (it is reported by the Intel therm_throt driver).
+func Throttle() ([]ThrottleStat, error) {
+	return ThrottleWithContext(context.Background())
+}
+
+func ThrottleWithContext(ctx context.Context) ([]ThrottleStat, error) {
+	dirs, err := filepath.Glob(common.HostSysWithContext(ctx, "devices/system/cpu/cpu[0-9]*/thermal_throttle"))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []ThrottleStat
+	for _, dir := range dirs {
+		cpuDir := filepath.Base(filepath.Dir(dir))
+		n, err := strconv.ParseInt(strings.TrimPrefix(cpuDir, "cpu"), 10, 32)
+		if err != nil {
+			continue
+		}
+		t := ThrottleStat{CPU: int32(n)}
+		t.CoreThrottleCount, _ = readSysUint(filepath.Join(dir, "core_throttle_count"))
+		t.CoreThrottleTime, _ = readSysUint(filepath.Join(dir, "core_throttle_total_time_ms"))
+		t.PackageThrottleCount, _ = readSysUint(filepath.Join(dir, "package_throttle_count"))
+		t.PackageThrottleTime, _ = readSysUint(filepath.Join(dir, "package_throttle_total_time_ms"))
+		ret = append(ret, t)
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].CPU < ret[j].CPU })
+	return ret, nil
+}
+
+// Throttled samples the throttling counters twice, interval apart, and
+// reports what changed in between.
+func Throttled(interval time.Duration) (*ThrottleReport, error) {
+	return ThrottledWithContext(context.Background(), interval)
+}
+
+func ThrottledWithContext(ctx context.Context, interval time.Duration) (*ThrottleReport, error) {
+	t1, err := ThrottleWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+
+	if err := common.Sleep(ctx, interval); err != nil {
+		return nil, err
+	}
+
+	t2, err := ThrottleWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := CalculateThrottle(t1, t2)
+	if err != nil {
+		return nil, err
+	}
+	ret.Interval = time.Since(start)
+	return ret, nil
+}
+
+// CalculateThrottle computes the throttling deltas between two samples
+// returned by Throttle. Interval is left for the caller to fill.
+func CalculateThrottle(t1, t2 []ThrottleStat) (*ThrottleReport, error) {
+	if len(t1) != len(t2) {
+		return nil, fmt.Errorf(
+			"received two CPU counts: %d != %d",
+			len(t1), len(t2),
+		)
+	}
+
+	ret := &ThrottleReport{
+		CPUs:          make([]ThrottleStat, len(t2)),
+		ThrottledCPUs: CPUSet{},
+	}
+	for i, cur := range t2 {
+		prev := t1[i]
+		if prev.CPU != cur.CPU {
+			return nil, fmt.Errorf("received two different CPUs: %d != %d", prev.CPU, cur.CPU)
+		}
+		d := ThrottleStat{
+			CPU:                  cur.CPU,
+			CoreThrottleCount:    counterDelta(prev.CoreThrottleCount, cur.CoreThrottleCount),
+			CoreThrottleTime:     counterDelta(prev.CoreThrottleTime, cur.CoreThrottleTime),
+			PackageThrottleCount: counterDelta(prev.PackageThrottleCount, cur.PackageThrottleCount),
+			PackageThrottleTime:  counterDelta(prev.PackageThrottleTime, cur.PackageThrottleTime),
+		}
+		ret.CPUs[i] = d
+		if d.CoreThrottleCount > 0 || d.PackageThrottleCount > 0 {
+			ret.Throttled = true
+			ret.ThrottledCPUs = append(ret.ThrottledCPUs, int(d.CPU))
+		}
+	}
+	return ret, nil
+}
+
+// counterDelta returns cur-prev, or 0 if the counter went backwards, e.g.
+// because it was reset.
+func counterDelta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
@@ -0,0 +1,64 @@
+//go:build linux
+
+package cpu
+
+import (
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func TestThrottle(t *testing.T) {
+	sys := t.TempDir()
+	t.Setenv("HOST_SYS", sys)
+	writeTestFiles(t, sys, map[string]string{
+		"devices/system/cpu/cpu10/thermal_throttle/core_throttle_count":            "3\n",
+		"devices/system/cpu/cpu10/thermal_throttle/core_throttle_total_time_ms":    "120\n",
+		"devices/system/cpu/cpu10/thermal_throttle/package_throttle_count":         "7\n",
+		"devices/system/cpu/cpu10/thermal_throttle/package_throttle_total_time_ms": "900\n",
+		"devices/system/cpu/cpu2/thermal_throttle/core_throttle_count":             "0\n",
+		"devices/system/cpu/cpu3/online":                                           "1\n",
+	})
+
+	stats, err := Throttle()
+	assert.Nil(t, err)
+	assert.DeepEqual(t, []ThrottleStat{
+		{CPU: 2},
+		{CPU: 10, CoreThrottleCount: 3, CoreThrottleTime: 120, PackageThrottleCount: 7, PackageThrottleTime: 900},
+	}, stats)
+}
+
+func TestCalculateThrottle(t *testing.T) {
+	t1 := []ThrottleStat{
+		{CPU: 0, CoreThrottleCount: 1, CoreThrottleTime: 10, PackageThrottleCount: 5, PackageThrottleTime: 50},
+		{CPU: 1, CoreThrottleCount: 2, CoreThrottleTime: 20, PackageThrottleCount: 5, PackageThrottleTime: 50},
+		{CPU: 2, CoreThrottleCount: 9, CoreThrottleTime: 90},
+	}
+	t2 := []ThrottleStat{
+		{CPU: 0, CoreThrottleCount: 1, CoreThrottleTime: 10, PackageThrottleCount: 6, PackageThrottleTime: 80},
+		{CPU: 1, CoreThrottleCount: 2, CoreThrottleTime: 20, PackageThrottleCount: 5, PackageThrottleTime: 50},
+		// reset, e.g. after a resume
+		{CPU: 2, CoreThrottleCount: 1, CoreThrottleTime: 5},
+	}
+
+	report, err := CalculateThrottle(t1, t2)
+	assert.Nil(t, err)
+	assert.Equal(t, true, report.Throttled)
+	assert.DeepEqual(t, CPUSet{0}, report.ThrottledCPUs)
+	assert.DeepEqual(t, []ThrottleStat{
+		{CPU: 0, PackageThrottleCount: 1, PackageThrottleTime: 30},
+		{CPU: 1},
+		{CPU: 2},
+	}, report.CPUs)
+
+	report, err = CalculateThrottle(t1, t1)
+	assert.Nil(t, err)
+	assert.Equal(t, false, report.Throttled)
+	assert.Equal(t, 0, len(report.ThrottledCPUs))
+
+	_, err = CalculateThrottle(t1, t2[:2])
+	assert.NotNil(t, err)
+	t2[1].CPU = 3
+	_, err = CalculateThrottle(t1, t2)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,113 @@
+//go:build linux
+
+package cpu
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+type VulnerabilityStatus string
+
+const (
+	VulnerabilityNotAffected VulnerabilityStatus = "Not affected"
+	VulnerabilityVulnerable  VulnerabilityStatus = "Vulnerable"
+	VulnerabilityMitigated   VulnerabilityStatus = "Mitigated"
+	VulnerabilityUnknown     VulnerabilityStatus = "Unknown"
+)
+
+// VulnerabilityStat is one entry of /sys/devices/system/cpu/vulnerabilities.
+// Mitigation holds the kernel text that follows the status, e.g. "PTI" for
+// "Mitigation: PTI", and Raw the unmodified file content.
+type VulnerabilityStat struct {
+	Name       string              `json:"name"`
+	Status     VulnerabilityStatus `json:"status"`
+	Mitigation string              `json:"mitigation,omitempty"`
+	Raw        string              `json:"raw"`
+}
+
+// VulnerabilityReport is the CPU security inventory of the host.
+//
+// Bugs is the "bugs" line of /proc/cpuinfo (x86 only). SMTControl is the
+// content of /sys/devices/system/cpu/smt/control: on, off, forceoff,
+// notsupported or notimplemented.
+type VulnerabilityReport struct {
+	Vulnerabilities []VulnerabilityStat `json:"vulnerabilities"`
+	Bugs            []string            `json:"bugs,omitempty"`
+	SMTControl      string              `json:"smtControl,omitempty"`
+	SMTActive       bool                `json:"smtActive"`
+}
+
+func (v VulnerabilityReport) String() string {
+	s, _ := json.Marshal(v)
+	return string(s)
+}
+
+// Vulnerable returns the entries the kernel reports as not mitigated.
+func (v VulnerabilityReport) Vulnerable() []VulnerabilityStat {
+	var ret []VulnerabilityStat
+	for _, vuln := range v.Vulnerabilities {
+		if vuln.Status == VulnerabilityVulnerable {
+			ret = append(ret, vuln)
+		}
+	}
+	return ret
+}
+
+func Vulnerabilities() (*VulnerabilityReport, error) {
+	return VulnerabilitiesWithContext(context.Background())
+}
+
+func VulnerabilitiesWithContext(ctx context.Context) (*VulnerabilityReport, error) {
+	files, err := filepath.Glob(common.HostSysWithContext(ctx, "devices/system/cpu/vulnerabilities/*"))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &VulnerabilityReport{}
+	for _, file := range files {
+		lines, err := common.ReadLines(file)
+		if err != nil || len(lines) == 0 {
+			continue
+		}
+		ret.Vulnerabilities = append(ret.Vulnerabilities, parseVulnerability(filepath.Base(file), lines[0]))
+	}
+
+	line, err := common.ReadLine(common.HostProcWithContext(ctx, "cpuinfo"), "bugs")
+	if err == nil {
+		if _, value, found := strings.Cut(line, ":"); found {
+			ret.Bugs = strings.Fields(value)
+		}
+	}
+
+	ret.SMTControl = readSysString(common.HostSysWithContext(ctx, "devices/system/cpu/smt/control"))
+	if v, err := readSysUint(common.HostSysWithContext(ctx, "devices/system/cpu/smt/active")); err == nil {
+		ret.SMTActive = v != 0
+	}
+	return ret, nil
+}
+
+func parseVulnerability(name, raw string) VulnerabilityStat {
+	raw = strings.TrimSpace(raw)
+	ret := VulnerabilityStat{Name: name, Raw: raw, Status: VulnerabilityUnknown}
+
+	// itlb_multihit prefixes its state with "KVM: "
+	text := strings.TrimPrefix(raw, "KVM: ")
+	switch {
+	case strings.HasPrefix(text, "Not affected"):
+		ret.Status = VulnerabilityNotAffected
+	case strings.HasPrefix(text, "Mitigation"):
+		ret.Status = VulnerabilityMitigated
+		ret.Mitigation = strings.TrimSpace(strings.TrimPrefix(text, "Mitigation:"))
+	case strings.HasPrefix(text, "Vulnerable"), strings.HasPrefix(text, "Processor vulnerable"):
+		ret.Status = VulnerabilityVulnerable
+		if _, detail, found := strings.Cut(text, ":"); found {
+			ret.Mitigation = strings.TrimSpace(detail)
+		}
+	}
+	return ret
+}
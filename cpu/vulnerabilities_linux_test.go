@@ -0,0 +1,46 @@
+//go:build linux
+
+package cpu
+
+import (
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func TestParseVulnerability(t *testing.T) {
+	for _, tt := range []struct {
+		raw        string
+		status     VulnerabilityStatus
+		mitigation string
+	}{
+		{"Not affected\n", VulnerabilityNotAffected, ""},
+		{"KVM: Mitigation: VMX disabled", VulnerabilityMitigated, "VMX disabled"},
+		{"Mitigation: PTI", VulnerabilityMitigated, "PTI"},
+		{"Mitigation: Clear CPU buffers; SMT vulnerable", VulnerabilityMitigated, "Clear CPU buffers; SMT vulnerable"},
+		{"Vulnerable", VulnerabilityVulnerable, ""},
+		{"Vulnerable: Clear CPU buffers attempted, no microcode; SMT Host state unknown", VulnerabilityVulnerable,
+			"Clear CPU buffers attempted, no microcode; SMT Host state unknown"},
+		{"Vulnerable: No microcode", VulnerabilityVulnerable, "No microcode"},
+		{"Processor vulnerable", VulnerabilityVulnerable, ""},
+		{"Unknown: Dependent on hypervisor status", VulnerabilityUnknown, ""},
+		{"", VulnerabilityUnknown, ""},
+	} {
+		v := parseVulnerability("mds", tt.raw)
+		assert.Equal(t, "mds", v.Name)
+		assert.Equal(t, tt.status, v.Status)
+		assert.Equal(t, tt.mitigation, v.Mitigation)
+	}
+}
+
+func TestVulnerabilityReportVulnerable(t *testing.T) {
+	report := VulnerabilityReport{Vulnerabilities: []VulnerabilityStat{
+		parseVulnerability("meltdown", "Not affected"),
+		parseVulnerability("mds", "Vulnerable: Clear CPU buffers attempted, no microcode"),
+		parseVulnerability("spectre_v1", "Mitigation: usercopy/swapgs barriers and __user pointer sanitization"),
+		parseVulnerability("gds", "Unknown: Dependent on hypervisor status"),
+	}}
+	vulnerable := report.Vulnerable()
+	assert.Equal(t, 1, len(vulnerable))
+	assert.Equal(t, "mds", vulnerable[0].Name)
+}
@@ -0,0 +1,255 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// Cgroup is a cgroup a process belongs to, as listed in /proc/<pid>/cgroup,
+// resolved to its directory. Version 2 entries have no Controllers; in a
+// hybrid layout a process has both v1 and v2 entries.
+//
+// Path is relative to the cgroup namespace of the caller, Dir is where the
+// cgroup is mounted in the caller's mount namespace.
+type Cgroup struct {
+	Version     int      `json:"version"`
+	Controllers []string `json:"controllers,omitempty"`
+	Path        string   `json:"path"`
+	Dir         string   `json:"dir"`
+}
+
+func (c Cgroup) String() string {
+	s, _ := json.Marshal(c)
+	return string(s)
+}
+
+// cgroupMountOptions are the cgroup v1 super options that are not
+// controllers.
+var cgroupMountOptions = []string{"rw", "ro", "xattr", "noprefix", "clone_children", "cpuset_v2_mode", "favordynmods", "all", "none"}
+
+// cgroupMount is a cgroup or cgroup2 entry of mountinfo.
+type cgroupMount struct {
+	version     int
+	controllers []string
+	root        string
+	mountPoint  string
+}
+
+// Cgroups returns every cgroup of the process, resolved against the cgroup
+// mounts of /proc/self/mountinfo, as the directories are opened by the
+// caller. HOST_PROC_MOUNTINFO overrides the mountinfo file.
+//
+// Dir is empty when the cgroup cannot be reached: it lies outside of the
+// cgroup namespace of the caller, shown as a path starting with "/..", or
+// no mount of its hierarchy holds it.
+func Cgroups(pid int32) ([]Cgroup, error) {
+	lines, err := common.ReadLines(common.HostProc(strconv.Itoa(int(pid)), "cgroup"))
+	if err != nil {
+		return nil, err
+	}
+	mounts, err := readCgroupMounts()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []Cgroup
+	for _, line := range lines {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		c := Cgroup{Version: 1, Path: fields[2]}
+		if fields[0] == "0" && fields[1] == "" {
+			c.Version = 2
+		} else {
+			c.Controllers = strings.Split(fields[1], ",")
+		}
+		c.Dir = resolveCgroupDir(c, mounts)
+		ret = append(ret, c)
+	}
+	return ret, nil
+}
+
+// CgroupDir returns the directory of the cgroup of the process for
+// controller, e.g. "memory", "cpu" or "name=systemd". A v1 hierarchy with
+// the controller is preferred, otherwise the v2 unified hierarchy is used,
+// which holds every controller. An empty controller selects the v2 cgroup.
+func CgroupDir(pid int32, controller string) (string, int, error) {
+	cgroups, err := Cgroups(pid)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var unified *Cgroup
+	for i, c := range cgroups {
+		if c.Version == 2 {
+			unified = &cgroups[i]
+			continue
+		}
+		if controller != "" && common.StringsHas(c.Controllers, controller) && c.Dir != "" {
+			return c.Dir, 1, nil
+		}
+	}
+	if unified != nil && unified.Dir != "" {
+		return unified.Dir, 2, nil
+	}
+	return "", 0, fmt.Errorf("no cgroup mount found for controller %q of pid %d", controller, pid)
+}
+
+// initCgroupNamespace is the link target of ns/cgroup in the initial cgroup
+// namespace, its inode is fixed by the kernel.
+const initCgroupNamespace = "cgroup:[4026531835]"
+
+// Containerized reports whether the process runs confined by its cgroups,
+// as in a container, whatever the runtime is called: it is in a cgroup
+// namespace other than the initial one, where its cgroup shows as "/", its
+// hierarchies are bind mounted from a subtree, or its cgroup has a memory
+// limit or a CPU quota.
+func Containerized(pid int32) (bool, error) {
+	link, err := os.Readlink(common.HostProc(strconv.Itoa(int(pid)), "ns", "cgroup"))
+	if err == nil && link != initCgroupNamespace {
+		return true, nil
+	}
+
+	mounts, err := readCgroupMounts()
+	if err != nil {
+		return false, err
+	}
+	for _, m := range mounts {
+		if m.root != "/" {
+			return true, nil
+		}
+	}
+
+	dir, version, err := CgroupDir(pid, "memory")
+	if err != nil {
+		return false, err
+	}
+	limit := dir + "/memory.max"
+	if version == 1 {
+		limit = dir + "/memory.limit_in_bytes"
+	}
+	if v, err := readCgroupValue(limit); err == nil && v != Unlimited {
+		return true, nil
+	}
+	if cpuStat, _, err := readCPULimits(pid); err == nil && cpuStat.Quota > 0 {
+		return true, nil
+	}
+	return false, nil
+}
+
+func readCgroupMounts() ([]cgroupMount, error) {
+	filename := common.GetEnv("HOST_PROC_MOUNTINFO", "")
+	if filename == "" {
+		filename = common.HostProc("self", "mountinfo")
+	}
+	lines, err := common.ReadLines(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []cgroupMount
+	for _, line := range lines {
+		// id parent major:minor root mount-point options [optional...] - fstype source super-options
+		before, after, found := strings.Cut(line, " - ")
+		if !found {
+			continue
+		}
+		fields, superFields := strings.Fields(before), strings.Fields(after)
+		if len(fields) < 5 || len(superFields) < 3 {
+			continue
+		}
+		m := cgroupMount{
+			root:       unescapeMountinfo(fields[3]),
+			mountPoint: unescapeMountinfo(fields[4]),
+		}
+		switch superFields[0] {
+		case "cgroup2":
+			m.version = 2
+		case "cgroup":
+			m.version = 1
+			for _, opt := range strings.Split(superFields[2], ",") {
+				if !common.StringsHas(cgroupMountOptions, opt) && !strings.HasPrefix(opt, "release_agent=") {
+					m.controllers = append(m.controllers, opt)
+				}
+			}
+		default:
+			continue
+		}
+		ret = append(ret, m)
+	}
+	return ret, nil
+}
+
+// resolveCgroupDir maps the cgroup path of c to a directory below the mount
+// point of its hierarchy. The mount root is not "/" when a cgroup subtree is
+// bind mounted, as done by container runtimes without cgroup namespaces.
+func resolveCgroupDir(c Cgroup, mounts []cgroupMount) string {
+	for _, elem := range strings.Split(c.Path, "/") {
+		if elem == ".." {
+			return "" // outside of the cgroup namespace
+		}
+	}
+	for _, m := range mounts {
+		if m.version != c.Version {
+			continue
+		}
+		if c.Version == 1 && !sameControllers(m.controllers, c.Controllers) {
+			continue
+		}
+		rel, err := filepath.Rel(m.root, c.Path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		mountPoint := m.mountPoint
+		if strings.HasPrefix(mountPoint, "/sys/") {
+			// mount points are seen from the host, honour HOST_SYS
+			mountPoint = common.HostSys(strings.TrimPrefix(mountPoint, "/sys/"))
+		}
+		dir := filepath.Join(mountPoint, rel)
+		if common.PathExists(dir) {
+			return dir
+		}
+		// another mount of the hierarchy may hold it
+	}
+	return ""
+}
+
+func sameControllers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, c := range b {
+		if !common.StringsHas(a, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// unescapeMountinfo decodes the octal escapes (\040 for a space) of
+// mountinfo paths.
+func unescapeMountinfo(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,171 @@
+//go:build linux
+
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+// fakeCgroupHost writes /proc/42/cgroup and a mountinfo, and points
+// HOST_PROC, HOST_PROC_MOUNTINFO and HOST_SYS at them. It returns the fake
+// /sys.
+func fakeCgroupHost(t *testing.T, cgroup, mountinfo string, dirs ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	proc, sys := filepath.Join(root, "proc"), filepath.Join(root, "sys")
+	files := map[string]string{
+		"proc/42/cgroup":      cgroup,
+		"proc/self/mountinfo": mountinfo,
+	}
+	for _, dir := range dirs {
+		files[filepath.Join("sys", dir, ".keep")] = ""
+	}
+	writeTestFiles(t, root, files)
+	t.Setenv("HOST_PROC", proc)
+	t.Setenv("HOST_PROC_MOUNTINFO", "")
+	t.Setenv("HOST_SYS", sys)
+	return sys
+}
+
+const mountinfoV2 = `22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+30 22 0:26 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:4 - cgroup2 cgroup2 rw,nsdelegate
+`
+
+func TestCgroupDirV2Namespace(t *testing.T) {
+	// a pod with a private cgroup namespace sees itself at the root
+	sys := fakeCgroupHost(t, "0::/\n", mountinfoV2, "fs/cgroup")
+	dir, version, err := CgroupDir(42, "memory")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, version)
+	assert.Equal(t, filepath.Join(sys, "fs/cgroup"), dir)
+}
+
+func TestCgroupDirV2Nested(t *testing.T) {
+	sys := fakeCgroupHost(t, "0::/kubepods.slice/pod1/cri-abc.scope\n", mountinfoV2,
+		"fs/cgroup/kubepods.slice/pod1/cri-abc.scope")
+	dir, version, err := CgroupDir(42, "cpu")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, version)
+	assert.Equal(t, filepath.Join(sys, "fs/cgroup/kubepods.slice/pod1/cri-abc.scope"), dir)
+}
+
+func TestCgroupDirUnreachable(t *testing.T) {
+	// the directory is not there, the hierarchy root must not be used
+	fakeCgroupHost(t, "0::/system.slice/foo.service\n", mountinfoV2, "fs/cgroup")
+	_, _, err := CgroupDir(42, "memory")
+	assert.NotNil(t, err)
+
+	// outside of the cgroup namespace of the caller
+	fakeCgroupHost(t, "0::/../../other.slice/bar.scope\n", mountinfoV2, "fs/cgroup", "fs/cgroup/other.slice/bar.scope")
+	cgroups, err := Cgroups(42)
+	assert.Nil(t, err)
+	assert.Equal(t, "", cgroups[0].Dir)
+	_, _, err = CgroupDir(42, "memory")
+	assert.NotNil(t, err)
+}
+
+func TestCgroupDirV1Hybrid(t *testing.T) {
+	// docker without cgroup namespace: the subtree of the container is
+	// bind mounted and mountinfo shows its root
+	const mountinfo = `22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+31 22 0:27 /docker/abc /sys/fs/cgroup/memory rw,nosuid - cgroup cgroup rw,memory
+32 22 0:28 /docker/abc /sys/fs/cgroup/cpu,cpuacct rw,nosuid - cgroup cgroup rw,cpu,cpuacct
+33 22 0:29 / /sys/fs/cgroup/systemd rw,nosuid - cgroup cgroup rw,xattr,name=systemd
+34 22 0:30 / /sys/fs/cgroup/unified rw,nosuid - cgroup2 cgroup2 rw
+35 22 0:31 / /mnt/with\040space rw - tmpfs tmpfs rw
+`
+	const cgroup = `12:memory:/docker/abc
+11:cpu,cpuacct:/docker/abc
+1:name=systemd:/docker/abc
+0::/docker/abc
+`
+	sys := fakeCgroupHost(t, cgroup, mountinfo,
+		"fs/cgroup/memory", "fs/cgroup/cpu,cpuacct", "fs/cgroup/systemd/docker/abc", "fs/cgroup/unified/docker/abc")
+
+	dir, version, err := CgroupDir(42, "memory")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, version)
+	assert.Equal(t, filepath.Join(sys, "fs/cgroup/memory"), dir)
+
+	dir, _, err = CgroupDir(42, "cpuacct")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(sys, "fs/cgroup/cpu,cpuacct"), dir)
+
+	dir, _, err = CgroupDir(42, "name=systemd")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(sys, "fs/cgroup/systemd/docker/abc"), dir)
+
+	// no v1 pids hierarchy, the unified one is used
+	dir, version, err = CgroupDir(42, "pids")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, version)
+	assert.Equal(t, filepath.Join(sys, "fs/cgroup/unified/docker/abc"), dir)
+
+	cgroups, err := Cgroups(42)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(cgroups))
+	assert.DeepEqual(t, []string{"cpu", "cpuacct"}, cgroups[1].Controllers)
+}
+
+func TestUnescapeMountinfo(t *testing.T) {
+	assert.Equal(t, "/mnt/with space", unescapeMountinfo(`/mnt/with\040space`))
+	assert.Equal(t, "/a\tb\\", unescapeMountinfo(`/a\011b\134`))
+	assert.Equal(t, `/bad\9`, unescapeMountinfo(`/bad\9`))
+}
+
+func TestContainerized(t *testing.T) {
+	nsCgroup := func(t *testing.T, link string) {
+		t.Helper()
+		dir := filepath.Join(common.HostProc("42"), "ns")
+		assert.Nil(t, os.MkdirAll(dir, 0o755))
+		assert.Nil(t, os.Symlink(link, filepath.Join(dir, "cgroup")))
+	}
+
+	// a host process in a systemd slice without limits
+	sys := fakeCgroupHost(t, "0::/user.slice/session-1.scope\n", mountinfoV2)
+	writeTestFiles(t, sys, map[string]string{
+		"fs/cgroup/user.slice/session-1.scope/memory.max": "max\n",
+		"fs/cgroup/user.slice/session-1.scope/cpu.max":    "max 100000\n",
+	})
+	nsCgroup(t, initCgroupNamespace)
+	containerized, err := Containerized(42)
+	assert.Nil(t, err)
+	assert.Equal(t, false, containerized)
+
+	// a pod with a private cgroup namespace, no limits and no .dockerenv
+	fakeCgroupHost(t, "0::/\n", mountinfoV2, "fs/cgroup")
+	nsCgroup(t, "cgroup:[4026532517]")
+	containerized, err = Containerized(42)
+	assert.Nil(t, err)
+	assert.Equal(t, true, containerized)
+
+	// the same pod when ns/cgroup cannot be read, by its memory limit
+	sys = fakeCgroupHost(t, "0::/\n", mountinfoV2)
+	writeTestFiles(t, sys, map[string]string{"fs/cgroup/memory.max": "536870912\n"})
+	containerized, err = Containerized(42)
+	assert.Nil(t, err)
+	assert.Equal(t, true, containerized)
+
+	// a service with a CPU quota
+	sys = fakeCgroupHost(t, "0::/system.slice/app.service\n", mountinfoV2)
+	writeTestFiles(t, sys, map[string]string{"fs/cgroup/system.slice/app.service/cpu.max": "50000 100000\n"})
+	nsCgroup(t, initCgroupNamespace)
+	containerized, err = Containerized(42)
+	assert.Nil(t, err)
+	assert.Equal(t, true, containerized)
+
+	// docker on cgroup v1 without cgroup namespace bind mounts its subtree
+	const mountinfo = `22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+31 22 0:27 /docker/abc /sys/fs/cgroup/memory rw,nosuid - cgroup cgroup rw,memory
+`
+	fakeCgroupHost(t, "5:memory:/docker/abc\n", mountinfo, "fs/cgroup/memory")
+	nsCgroup(t, initCgroupNamespace)
+	containerized, err = Containerized(42)
+	assert.Nil(t, err)
+	assert.Equal(t, true, containerized)
+}
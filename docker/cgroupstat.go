@@ -0,0 +1,470 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// Unlimited is the value of a limit set to "max", or to no limit at all on
+// cgroup v1.
+const Unlimited = math.MaxUint64
+
+// v1 reports no limit as LONG_MAX rounded down to the page size
+const cgroupV1Unlimited = 1 << 62
+
+// CgroupStat is a snapshot of the cgroup of a process, following the cgroup
+// v2 interface files. On cgroup v1 the closest v1 files are mapped to the
+// same fields; values with no v1 equivalent are left to zero.
+type CgroupStat struct {
+	Version int               `json:"version"`
+	Memory  CgroupMemoryStat  `json:"memory"`
+	CPU     CgroupCPUStat     `json:"cpu"`
+	Pids    CgroupPidsStat    `json:"pids"`
+	IO      []CgroupIOStat    `json:"io"`
+	Dirs    map[string]string `json:"dirs"`
+}
+
+// CgroupMemoryStat holds memory.current, the memory.min/low/high/max
+// protections and limits, memory.peak, the swap usage and memory.stat, in
+// bytes except for the page fault counters. Stat holds every memory.stat
+// key; on v1 the total_ prefix is removed.
+//
+// v1 mapping: memory.usage_in_bytes, soft_limit_in_bytes (Low),
+// limit_in_bytes (Max), max_usage_in_bytes (Peak), memsw.* minus the memory
+// values (Swap), kmem.usage_in_bytes (Kernel), rss (Anon), cache (File),
+// rss_huge (AnonTHP), mapped_file (FileMapped).
+type CgroupMemoryStat struct {
+	Current           uint64             `json:"current"`
+	Min               uint64             `json:"min"`
+	Low               uint64             `json:"low"`
+	High              uint64             `json:"high"`
+	Max               uint64             `json:"max"`
+	Peak              uint64             `json:"peak"`
+	SwapCurrent       uint64             `json:"swapCurrent"`
+	SwapMax           uint64             `json:"swapMax"`
+	Anon              uint64             `json:"anon"`
+	File              uint64             `json:"file"`
+	Kernel            uint64             `json:"kernel"`
+	KernelStack       uint64             `json:"kernelStack"`
+	Pagetables        uint64             `json:"pagetables"`
+	Sock              uint64             `json:"sock"`
+	Shmem             uint64             `json:"shmem"`
+	FileMapped        uint64             `json:"fileMapped"`
+	FileDirty         uint64             `json:"fileDirty"`
+	FileWriteback     uint64             `json:"fileWriteback"`
+	AnonTHP           uint64             `json:"anonThp"`
+	ActiveAnon        uint64             `json:"activeAnon"`
+	InactiveAnon      uint64             `json:"inactiveAnon"`
+	ActiveFile        uint64             `json:"activeFile"`
+	InactiveFile      uint64             `json:"inactiveFile"`
+	Unevictable       uint64             `json:"unevictable"`
+	Slab              uint64             `json:"slab"`
+	SlabReclaimable   uint64             `json:"slabReclaimable"`
+	SlabUnreclaimable uint64             `json:"slabUnreclaimable"`
+	Pgfault           uint64             `json:"pgfault"`
+	Pgmajfault        uint64             `json:"pgmajfault"`
+	Events            CgroupMemoryEvents `json:"events"`
+	Stat              map[string]uint64  `json:"stat"`
+}
+
+// CgroupMemoryEvents holds memory.events. On v1 Max is memory.failcnt and
+// OOMKill the oom_kill line of memory.oom_control (Linux 4.13+).
+type CgroupMemoryEvents struct {
+	Low          uint64 `json:"low"`
+	High         uint64 `json:"high"`
+	Max          uint64 `json:"max"`
+	OOM          uint64 `json:"oom"`
+	OOMKill      uint64 `json:"oomKill"`
+	OOMGroupKill uint64 `json:"oomGroupKill"`
+}
+
+// CgroupCPUStat holds cpu.stat, cpu.max and cpu.weight. Quota is -1 when
+// unlimited. Times are in microseconds.
+//
+// v1 mapping: cpuacct.usage and cpuacct.stat (Usage, User, System),
+// cpu.stat (periods and throttling), cpu.cfs_quota_us and cpu.cfs_period_us
+// (Quota, Period), cpu.shares converted to a weight.
+type CgroupCPUStat struct {
+	UsageUsec     uint64 `json:"usageUsec"`
+	UserUsec      uint64 `json:"userUsec"`
+	SystemUsec    uint64 `json:"systemUsec"`
+	NrPeriods     uint64 `json:"nrPeriods"`
+	NrThrottled   uint64 `json:"nrThrottled"`
+	ThrottledUsec uint64 `json:"throttledUsec"`
+	NrBursts      uint64 `json:"nrBursts"`
+	BurstUsec     uint64 `json:"burstUsec"`
+	Quota         int64  `json:"quota"`
+	Period        uint64 `json:"period"`
+	Weight        uint64 `json:"weight"`
+}
+
+// CgroupPidsStat holds pids.current and pids.max, identical on v1.
+type CgroupPidsStat struct {
+	Current uint64 `json:"current"`
+	Max     uint64 `json:"max"`
+}
+
+// CgroupIOStat is a device line of io.stat. On v1 the bytes and IOs come
+// from blkio.throttle.io_service_bytes and io_serviced, discards are not
+// available.
+type CgroupIOStat struct {
+	Device string `json:"device"`
+	Major  uint64 `json:"major"`
+	Minor  uint64 `json:"minor"`
+	Rbytes uint64 `json:"rbytes"`
+	Wbytes uint64 `json:"wbytes"`
+	Rios   uint64 `json:"rios"`
+	Wios   uint64 `json:"wios"`
+	Dbytes uint64 `json:"dbytes"`
+	Dios   uint64 `json:"dios"`
+}
+
+func (c CgroupStat) String() string {
+	s, _ := json.Marshal(c)
+	return string(s)
+}
+
+// CgroupStats returns a snapshot of the cgroup of the process.
+func CgroupStats(pid int32) (*CgroupStat, error) {
+	memoryDir, version, err := CgroupDir(pid, "memory")
+	if err != nil {
+		return nil, err
+	}
+	ret := &CgroupStat{Version: version, Dirs: map[string]string{"memory": memoryDir}}
+
+	if version == 2 {
+		ret.Memory = readCgroupV2Memory(memoryDir)
+		ret.CPU = readCgroupV2CPU(memoryDir)
+		ret.Pids = readCgroupPids(memoryDir)
+		ret.IO = readCgroupV2IO(memoryDir)
+		return ret, nil
+	}
+
+	ret.Memory = readCgroupV1Memory(memoryDir)
+	if cpuDir, v, err := CgroupDir(pid, "cpu"); err == nil && v == 1 {
+		ret.Dirs["cpu"] = cpuDir
+		cpuacctDir, v, err := CgroupDir(pid, "cpuacct")
+		if err != nil || v != 1 {
+			cpuacctDir = cpuDir
+		}
+		ret.Dirs["cpuacct"] = cpuacctDir
+		ret.CPU = readCgroupV1CPU(cpuDir, cpuacctDir)
+	}
+	if pidsDir, v, err := CgroupDir(pid, "pids"); err == nil && v == 1 {
+		ret.Dirs["pids"] = pidsDir
+		ret.Pids = readCgroupPids(pidsDir)
+	}
+	if blkioDir, v, err := CgroupDir(pid, "blkio"); err == nil && v == 1 {
+		ret.Dirs["blkio"] = blkioDir
+		ret.IO = readCgroupV1IO(blkioDir)
+	}
+	return ret, nil
+}
+
+func readCgroupV2Memory(dir string) CgroupMemoryStat {
+	m := CgroupMemoryStat{Stat: readCgroupKeyValues(dir + "/memory.stat")}
+	m.Current, _ = readCgroupValue(dir + "/memory.current")
+	m.Min, _ = readCgroupValue(dir + "/memory.min")
+	m.Low, _ = readCgroupValue(dir + "/memory.low")
+	m.High, _ = readCgroupValue(dir + "/memory.high")
+	m.Max, _ = readCgroupValue(dir + "/memory.max")
+	m.Peak, _ = readCgroupValue(dir + "/memory.peak")
+	m.SwapCurrent, _ = readCgroupValue(dir + "/memory.swap.current")
+	m.SwapMax, _ = readCgroupValue(dir + "/memory.swap.max")
+
+	m.Anon = m.Stat["anon"]
+	m.File = m.Stat["file"]
+	m.Kernel = m.Stat["kernel"]
+	m.KernelStack = m.Stat["kernel_stack"]
+	m.Pagetables = m.Stat["pagetables"]
+	m.Sock = m.Stat["sock"]
+	m.Shmem = m.Stat["shmem"]
+	m.FileMapped = m.Stat["file_mapped"]
+	m.FileDirty = m.Stat["file_dirty"]
+	m.FileWriteback = m.Stat["file_writeback"]
+	m.AnonTHP = m.Stat["anon_thp"]
+	m.ActiveAnon = m.Stat["active_anon"]
+	m.InactiveAnon = m.Stat["inactive_anon"]
+	m.ActiveFile = m.Stat["active_file"]
+	m.InactiveFile = m.Stat["inactive_file"]
+	m.Unevictable = m.Stat["unevictable"]
+	m.Slab = m.Stat["slab"]
+	m.SlabReclaimable = m.Stat["slab_reclaimable"]
+	m.SlabUnreclaimable = m.Stat["slab_unreclaimable"]
+	m.Pgfault = m.Stat["pgfault"]
+	m.Pgmajfault = m.Stat["pgmajfault"]
+
+	events := readCgroupKeyValues(dir + "/memory.events")
+	m.Events = CgroupMemoryEvents{
+		Low:          events["low"],
+		High:         events["high"],
+		Max:          events["max"],
+		OOM:          events["oom"],
+		OOMKill:      events["oom_kill"],
+		OOMGroupKill: events["oom_group_kill"],
+	}
+	return m
+}
+
+func readCgroupV1Memory(dir string) CgroupMemoryStat {
+	m := CgroupMemoryStat{Stat: make(map[string]uint64)}
+	for key, value := range readCgroupKeyValues(dir + "/memory.stat") {
+		if strings.HasPrefix(key, "total_") {
+			m.Stat[strings.TrimPrefix(key, "total_")] = value
+		}
+	}
+	m.Current, _ = readCgroupValue(dir + "/memory.usage_in_bytes")
+	m.Low, _ = readCgroupValue(dir + "/memory.soft_limit_in_bytes")
+	m.Max, _ = readCgroupValue(dir + "/memory.limit_in_bytes")
+	m.Peak, _ = readCgroupValue(dir + "/memory.max_usage_in_bytes")
+	if m.Low == Unlimited {
+		m.Low = 0 // no soft limit, no protection
+	}
+	if swUsage, err := readCgroupValue(dir + "/memory.memsw.usage_in_bytes"); err == nil && swUsage > m.Current {
+		m.SwapCurrent = swUsage - m.Current
+	}
+	if swLimit, err := readCgroupValue(dir + "/memory.memsw.limit_in_bytes"); err == nil {
+		if swLimit == Unlimited || m.Max == Unlimited {
+			m.SwapMax = Unlimited
+		} else if swLimit > m.Max {
+			m.SwapMax = swLimit - m.Max
+		}
+	}
+	m.Kernel, _ = readCgroupValue(dir + "/memory.kmem.usage_in_bytes")
+
+	m.Anon = m.Stat["rss"]
+	m.File = m.Stat["cache"]
+	m.Shmem = m.Stat["shmem"]
+	m.FileMapped = m.Stat["mapped_file"]
+	m.FileDirty = m.Stat["dirty"]
+	m.FileWriteback = m.Stat["writeback"]
+	m.AnonTHP = m.Stat["rss_huge"]
+	m.ActiveAnon = m.Stat["active_anon"]
+	m.InactiveAnon = m.Stat["inactive_anon"]
+	m.ActiveFile = m.Stat["active_file"]
+	m.InactiveFile = m.Stat["inactive_file"]
+	m.Unevictable = m.Stat["unevictable"]
+	m.Pgfault = m.Stat["pgfault"]
+	m.Pgmajfault = m.Stat["pgmajfault"]
+
+	m.Events.Max, _ = readCgroupValue(dir + "/memory.failcnt")
+	m.Events.OOMKill = readCgroupKeyValues(dir + "/memory.oom_control")["oom_kill"]
+	return m
+}
+
+func readCgroupV2CPU(dir string) CgroupCPUStat {
+	stat := readCgroupKeyValues(dir + "/cpu.stat")
+	c := CgroupCPUStat{
+		UsageUsec:     stat["usage_usec"],
+		UserUsec:      stat["user_usec"],
+		SystemUsec:    stat["system_usec"],
+		NrPeriods:     stat["nr_periods"],
+		NrThrottled:   stat["nr_throttled"],
+		ThrottledUsec: stat["throttled_usec"],
+		NrBursts:      stat["nr_bursts"],
+		BurstUsec:     stat["burst_usec"],
+		Quota:         -1,
+		Period:        100000,
+	}
+	// "max 100000" or "50000 100000"
+	if data, err := common.ReadFileNoStat(dir + "/cpu.max"); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) > 0 && fields[0] != "max" {
+			c.Quota, _ = strconv.ParseInt(fields[0], 10, 64)
+		}
+		if len(fields) > 1 {
+			c.Period, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	c.Weight, _ = readCgroupValue(dir + "/cpu.weight")
+	return c
+}
+
+func readCgroupV1CPU(cpuDir, cpuacctDir string) CgroupCPUStat {
+	stat := readCgroupKeyValues(cpuDir + "/cpu.stat")
+	c := CgroupCPUStat{
+		NrPeriods:     stat["nr_periods"],
+		NrThrottled:   stat["nr_throttled"],
+		ThrottledUsec: stat["throttled_time"] / 1000,
+		Quota:         -1,
+	}
+	if usage, err := readCgroupValue(cpuacctDir + "/cpuacct.usage"); err == nil {
+		c.UsageUsec = usage / 1000
+	}
+	// cpuacct.stat is in USER_HZ, 100 on every supported architecture
+	acct := readCgroupKeyValues(cpuacctDir + "/cpuacct.stat")
+	c.UserUsec = acct["user"] * 10000
+	c.SystemUsec = acct["system"] * 10000
+
+	if data, err := common.ReadFileNoStat(cpuDir + "/cpu.cfs_quota_us"); err == nil {
+		c.Quota, _ = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	}
+	c.Period, _ = readCgroupValue(cpuDir + "/cpu.cfs_period_us")
+	if shares, err := readCgroupValue(cpuDir + "/cpu.shares"); err == nil && shares >= 2 {
+		// same conversion as the container runtimes, 1024 shares is 100
+		c.Weight = 1 + ((shares-2)*9999)/262142
+	}
+	return c
+}
+
+func readCgroupPids(dir string) CgroupPidsStat {
+	var p CgroupPidsStat
+	p.Current, _ = readCgroupValue(dir + "/pids.current")
+	p.Max, _ = readCgroupValue(dir + "/pids.max")
+	return p
+}
+
+// readCgroupV2IO parses io.stat lines such as
+// "8:0 rbytes=1 wbytes=2 rios=3 wios=4 dbytes=0 dios=0".
+func readCgroupV2IO(dir string) []CgroupIOStat {
+	lines, err := common.ReadLines(dir + "/io.stat")
+	if err != nil {
+		return nil
+	}
+	var ret []CgroupIOStat
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		io, ok := newCgroupIOStat(fields[0])
+		if !ok {
+			continue
+		}
+		for _, field := range fields[1:] {
+			key, value, _ := strings.Cut(field, "=")
+			v, _ := strconv.ParseUint(value, 10, 64)
+			switch key {
+			case "rbytes":
+				io.Rbytes = v
+			case "wbytes":
+				io.Wbytes = v
+			case "rios":
+				io.Rios = v
+			case "wios":
+				io.Wios = v
+			case "dbytes":
+				io.Dbytes = v
+			case "dios":
+				io.Dios = v
+			}
+		}
+		ret = append(ret, io)
+	}
+	return ret
+}
+
+// readCgroupV1IO merges the "8:0 Read 123" lines of
+// blkio.throttle.io_service_bytes and io_serviced.
+func readCgroupV1IO(dir string) []CgroupIOStat {
+	devices := make(map[string]*CgroupIOStat)
+	for _, file := range []string{"blkio.throttle.io_service_bytes", "blkio.throttle.io_serviced"} {
+		lines, err := common.ReadLines(dir + "/" + file)
+		if err != nil {
+			continue
+		}
+		for _, line := range lines {
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				continue
+			}
+			io, ok := devices[fields[0]]
+			if !ok {
+				stat, ok := newCgroupIOStat(fields[0])
+				if !ok {
+					continue
+				}
+				io = &stat
+				devices[fields[0]] = io
+			}
+			v, _ := strconv.ParseUint(fields[2], 10, 64)
+			switch {
+			case file == "blkio.throttle.io_service_bytes" && fields[1] == "Read":
+				io.Rbytes = v
+			case file == "blkio.throttle.io_service_bytes" && fields[1] == "Write":
+				io.Wbytes = v
+			case file == "blkio.throttle.io_serviced" && fields[1] == "Read":
+				io.Rios = v
+			case file == "blkio.throttle.io_serviced" && fields[1] == "Write":
+				io.Wios = v
+			}
+		}
+	}
+
+	ret := make([]CgroupIOStat, 0, len(devices))
+	for _, io := range devices {
+		ret = append(ret, *io)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Major != ret[j].Major {
+			return ret[i].Major < ret[j].Major
+		}
+		return ret[i].Minor < ret[j].Minor
+	})
+	return ret
+}
+
+func newCgroupIOStat(device string) (CgroupIOStat, bool) {
+	major, minor, found := strings.Cut(device, ":")
+	if !found {
+		return CgroupIOStat{}, false
+	}
+	io := CgroupIOStat{Device: device}
+	var err error
+	if io.Major, err = strconv.ParseUint(major, 10, 64); err != nil {
+		return CgroupIOStat{}, false
+	}
+	if io.Minor, err = strconv.ParseUint(minor, 10, 64); err != nil {
+		return CgroupIOStat{}, false
+	}
+	return io, true
+}
+
+// readCgroupValue reads a single value cgroup file. "max" and the v1 no
+// limit value are returned as Unlimited.
+func readCgroupValue(filename string) (uint64, error) {
+	data, err := common.ReadFileNoStat(filename)
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return Unlimited, nil
+	}
+	v, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	if v >= cgroupV1Unlimited {
+		return Unlimited, nil
+	}
+	return v, nil
+}
+
+// readCgroupKeyValues reads flat keyed files such as memory.stat and
+// cpu.stat. Missing files yield an empty map.
+func readCgroupKeyValues(filename string) map[string]uint64 {
+	ret := make(map[string]uint64)
+	data, err := common.ReadFileNoStat(filename)
+	if err != nil {
+		return ret
+	}
+	for _, line := range splitLines(string(data)) {
+		fields := splitFields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		ret[fields[0]] = v
+	}
+	return ret
+}
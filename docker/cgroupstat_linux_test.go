@@ -0,0 +1,122 @@
+//go:build linux
+
+package docker
+
+import (
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func TestCgroupStatsV2(t *testing.T) {
+	sys := fakeCgroupHost(t, "0::/app.slice\n", mountinfoV2, "fs/cgroup/app.slice")
+	writeTestFiles(t, sys, map[string]string{
+		"fs/cgroup/app.slice/memory.current":      "1048576\n",
+		"fs/cgroup/app.slice/memory.min":          "0\n",
+		"fs/cgroup/app.slice/memory.high":         "max\n",
+		"fs/cgroup/app.slice/memory.max":          "2097152\n",
+		"fs/cgroup/app.slice/memory.swap.max":     "0\n",
+		"fs/cgroup/app.slice/memory.stat":         "anon 524288\nfile 262144\ninactive_file 131072\nslab_reclaimable 4096\npgmajfault 7\n",
+		"fs/cgroup/app.slice/memory.events":       "low 0\nhigh 0\nmax 12\noom 2\noom_kill 1\noom_group_kill 0\n",
+		"fs/cgroup/app.slice/cpu.stat":            "usage_usec 5000\nuser_usec 3000\nsystem_usec 2000\nnr_periods 10\nnr_throttled 4\nthrottled_usec 900\n",
+		"fs/cgroup/app.slice/cpu.max":             "50000 100000\n",
+		"fs/cgroup/app.slice/cpu.weight":          "100\n",
+		"fs/cgroup/app.slice/pids.current":        "12\n",
+		"fs/cgroup/app.slice/pids.max":            "max\n",
+		"fs/cgroup/app.slice/io.stat":             "8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n253:1 rbytes=1 wbytes=2 rios=3 wios=4 dbytes=5 dios=6\nbogus\n",
+		"fs/cgroup/app.slice/cgroup.controllers":  "cpu io memory pids\n",
+		"fs/cgroup/app.slice/memory.swap.current": "0\n",
+	})
+
+	stat, err := CgroupStats(42)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, stat.Version)
+
+	m := stat.Memory
+	assert.Equal(t, uint64(1048576), m.Current)
+	assert.Equal(t, uint64(Unlimited), m.High)
+	assert.Equal(t, uint64(2097152), m.Max)
+	assert.Equal(t, uint64(0), m.SwapMax)
+	assert.Equal(t, uint64(524288), m.Anon)
+	assert.Equal(t, uint64(131072), m.InactiveFile)
+	assert.Equal(t, uint64(4096), m.SlabReclaimable)
+	assert.Equal(t, uint64(7), m.Pgmajfault)
+	assert.Equal(t, CgroupMemoryEvents{Max: 12, OOM: 2, OOMKill: 1}, m.Events)
+
+	assert.Equal(t, uint64(5000), stat.CPU.UsageUsec)
+	assert.Equal(t, uint64(4), stat.CPU.NrThrottled)
+	assert.Equal(t, int64(50000), stat.CPU.Quota)
+	assert.Equal(t, uint64(100000), stat.CPU.Period)
+	assert.Equal(t, uint64(100), stat.CPU.Weight)
+
+	assert.Equal(t, CgroupPidsStat{Current: 12, Max: Unlimited}, stat.Pids)
+
+	assert.DeepEqual(t, []CgroupIOStat{
+		{Device: "8:0", Major: 8, Minor: 0, Rbytes: 4096, Wbytes: 8192, Rios: 1, Wios: 2},
+		{Device: "253:1", Major: 253, Minor: 1, Rbytes: 1, Wbytes: 2, Rios: 3, Wios: 4, Dbytes: 5, Dios: 6},
+	}, stat.IO)
+}
+
+func TestCgroupStatsV1(t *testing.T) {
+	const mountinfo = `22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+31 22 0:27 / /sys/fs/cgroup/memory rw,nosuid - cgroup cgroup rw,memory
+32 22 0:28 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid - cgroup cgroup rw,cpu,cpuacct
+33 22 0:29 / /sys/fs/cgroup/pids rw,nosuid - cgroup cgroup rw,pids
+34 22 0:30 / /sys/fs/cgroup/blkio rw,nosuid - cgroup cgroup rw,blkio
+`
+	const cgroup = `5:memory:/docker/abc
+4:cpu,cpuacct:/docker/abc
+3:pids:/docker/abc
+2:blkio:/docker/abc
+`
+	sys := fakeCgroupHost(t, cgroup, mountinfo)
+	writeTestFiles(t, sys, map[string]string{
+		"fs/cgroup/memory/docker/abc/memory.usage_in_bytes":          "1048576\n",
+		"fs/cgroup/memory/docker/abc/memory.limit_in_bytes":          "9223372036854771712\n",
+		"fs/cgroup/memory/docker/abc/memory.soft_limit_in_bytes":     "9223372036854771712\n",
+		"fs/cgroup/memory/docker/abc/memory.memsw.usage_in_bytes":    "1572864\n",
+		"fs/cgroup/memory/docker/abc/memory.memsw.limit_in_bytes":    "9223372036854771712\n",
+		"fs/cgroup/memory/docker/abc/memory.failcnt":                 "3\n",
+		"fs/cgroup/memory/docker/abc/memory.oom_control":             "oom_kill_disable 0\nunder_oom 0\noom_kill 1\n",
+		"fs/cgroup/memory/docker/abc/memory.stat":                    "rss 1\ncache 2\ntotal_rss 524288\ntotal_cache 262144\ntotal_mapped_file 4096\ntotal_inactive_file 131072\n",
+		"fs/cgroup/cpu,cpuacct/docker/abc/cpuacct.usage":             "5000000\n",
+		"fs/cgroup/cpu,cpuacct/docker/abc/cpuacct.stat":              "user 30\nsystem 20\n",
+		"fs/cgroup/cpu,cpuacct/docker/abc/cpu.stat":                  "nr_periods 10\nnr_throttled 4\nthrottled_time 900000\n",
+		"fs/cgroup/cpu,cpuacct/docker/abc/cpu.cfs_quota_us":          "-1\n",
+		"fs/cgroup/cpu,cpuacct/docker/abc/cpu.cfs_period_us":         "100000\n",
+		"fs/cgroup/cpu,cpuacct/docker/abc/cpu.shares":                "1024\n",
+		"fs/cgroup/pids/docker/abc/pids.current":                     "3\n",
+		"fs/cgroup/pids/docker/abc/pids.max":                         "100\n",
+		"fs/cgroup/blkio/docker/abc/blkio.throttle.io_service_bytes": "8:16 Read 4096\n8:16 Write 8192\n8:0 Read 1\n8:16 Total 12288\nTotal 12289\n",
+		"fs/cgroup/blkio/docker/abc/blkio.throttle.io_serviced":      "8:16 Read 1\n8:16 Write 2\n8:0 Read 1\nTotal 4\n",
+	})
+
+	stat, err := CgroupStats(42)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, stat.Version)
+
+	m := stat.Memory
+	assert.Equal(t, uint64(1048576), m.Current)
+	assert.Equal(t, uint64(Unlimited), m.Max)
+	assert.Equal(t, uint64(0), m.Low)
+	assert.Equal(t, uint64(524288), m.SwapCurrent)
+	assert.Equal(t, uint64(Unlimited), m.SwapMax)
+	assert.Equal(t, uint64(524288), m.Anon)
+	assert.Equal(t, uint64(262144), m.File)
+	assert.Equal(t, uint64(4096), m.FileMapped)
+	assert.Equal(t, uint64(3), m.Events.Max)
+	assert.Equal(t, uint64(1), m.Events.OOMKill)
+
+	assert.Equal(t, uint64(5000), stat.CPU.UsageUsec)
+	assert.Equal(t, uint64(300000), stat.CPU.UserUsec)
+	assert.Equal(t, uint64(900), stat.CPU.ThrottledUsec)
+	assert.Equal(t, int64(-1), stat.CPU.Quota)
+	assert.Equal(t, uint64(39), stat.CPU.Weight)
+
+	assert.Equal(t, CgroupPidsStat{Current: 3, Max: 100}, stat.Pids)
+
+	assert.DeepEqual(t, []CgroupIOStat{
+		{Device: "8:0", Major: 8, Minor: 0, Rbytes: 1, Rios: 1},
+		{Device: "8:16", Major: 8, Minor: 16, Rbytes: 4096, Wbytes: 8192, Rios: 1, Wios: 2},
+	}, stat.IO)
+}
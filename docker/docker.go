@@ -6,33 +6,40 @@ import (
 	"fmt"
 	"os"
 	"runtime"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ravoni4devs/syspector/internal/common"
-)
-
-const (
-	cgroupBasePath = "/sys/fs/cgroup"
+	"github.com/ravoni4devs/syspector/mem"
 )
 
 var (
 	cgroupVersion   = 2
 	cgroupDetectErr error
+
+	// memory cgroup directory of the running process
+	memoryCgroupDir string
 )
 
+// VirtualMemoryStat is the memory of the container. Used is the working set,
+// the cgroup usage minus its inactive file pages, so that it has the same
+// meaning as mem.VirtualMemoryStat.Used on the host.
 type VirtualMemoryStat struct {
 	Total       uint64  `json:"total"`
 	Available   uint64  `json:"available"`
 	Used        uint64  `json:"used"`
 	UsedPercent float64 `json:"usedPercent"`
 	Free        uint64  `json:"free"`
+	WorkingSet  uint64  `json:"workingSet"`
 }
 
+// DockerStat is the memory and CPU usage of the container. CpuPercent is
+// relative to a single CPU, see CPUQuota for the usage against the CPUs the
+// container is allowed to use.
 type DockerStat struct {
 	Memory     VirtualMemoryStat `json:"memory"`
 	CpuPercent float64           `json:"cpuPercent"`
+	CPUQuota   CPUQuotaStat      `json:"cpuQuota"`
 }
 
 func (m VirtualMemoryStat) String() string {
@@ -52,54 +59,27 @@ func GetStat(duration time.Duration) (DockerStat, error) {
 		return stat, err
 	}
 
-	c, err := CpuPercent(duration)
+	c, err := CPUQuota(duration)
 	if err != nil {
 		return stat, err
 	}
 
 	stat.Memory = m
-	stat.CpuPercent = c
+	stat.CpuPercent = common.ParseFloat(fmt.Sprintf("%.2f", c.UsagePercent))
+	stat.CPUQuota = c
 	return stat, nil
 }
 
 func VirtualMemory() (VirtualMemoryStat, error) {
 	var stat VirtualMemoryStat
-	if cgroupDetectErr != nil {
-		return stat, cgroupDetectErr
-	}
 
-	if cgroupVersion == 1 {
-		usedBytes, err := common.ReadFileNoStat(fmt.Sprintf("%s/memory/memory.usage_in_bytes", cgroupBasePath))
-		if err != nil {
-			return stat, err
-		}
-		limitBytes, err := common.ReadFileNoStat(fmt.Sprintf("%s/memory/memory.limit_in_bytes", cgroupBasePath))
-		if err != nil {
-			return stat, err
-		}
-		stat.Used = common.ParseUint64(string(usedBytes))
-		stat.Total = common.ParseUint64(string(limitBytes))
-	} else {
-		usedBytes, err := common.ReadFileNoStat(fmt.Sprintf("%s/memory.current", cgroupBasePath))
-		if err != nil {
-			return stat, err
-		}
-		limitBytes, err := common.ReadFileNoStat(fmt.Sprintf("%s/memory.max", cgroupBasePath))
-		if err != nil {
-			return stat, err
-		}
-		stat.Used = common.ParseUint64(string(usedBytes))
-
-		if strings.TrimSpace(string(limitBytes)) == "max" {
-			meminfo, err := os.ReadFile("/proc/meminfo")
-			if err != nil {
-				return stat, err
-			}
-			stat.Total = parseMemInfoTotal(string(meminfo))
-		} else {
-			stat.Total = common.ParseUint64(string(limitBytes))
-		}
+	ws, err := WorkingSet()
+	if err != nil {
+		return stat, err
 	}
+	stat.Total = ws.Limit
+	stat.WorkingSet = ws.WorkingSet
+	stat.Used = ws.WorkingSet
 
 	if stat.Total > stat.Used {
 		stat.Free = stat.Total - stat.Used
@@ -116,67 +96,113 @@ func VirtualMemory() (VirtualMemoryStat, error) {
 	return stat, nil
 }
 
-func CpuPercent(seconds time.Duration) (float64, error) {
+// WorkingSet returns the working set estimate of the container and its
+// reclaimable memory breakdown, computed as mem.WorkingSet does for the
+// host. Without a memory limit, Limit is the memory of the host.
+func WorkingSet() (mem.WorkingSetStat, error) {
+	var stat mem.WorkingSetStat
 	if cgroupDetectErr != nil {
-		return 0, cgroupDetectErr
+		return stat, cgroupDetectErr
 	}
 
-	var usage1, usage2 uint64
-	var err error
-
+	usageFile, limitFile := "/memory.current", "/memory.max"
 	if cgroupVersion == 1 {
-		usage1, err = readCpuacctUsage()
-		if err != nil {
-			return 0, err
-		}
-	} else {
-		usage1, err = readCgroupV2CpuUsage()
-		if err != nil {
-			return 0, err
-		}
+		usageFile, limitFile = "/memory.usage_in_bytes", "/memory.limit_in_bytes"
+	}
+	usage, err := readCgroupValue(memoryCgroupDir + usageFile)
+	if err != nil {
+		return stat, err
+	}
+	limit, err := readCgroupValue(memoryCgroupDir + limitFile)
+	if err != nil {
+		return stat, err
 	}
+	return workingSet(usage, limit)
+}
 
-	time.Sleep(seconds)
+// workingSet reads memory.stat and derives the working set of a cgroup
+// using usage bytes out of limit.
+func workingSet(usage, limit uint64) (mem.WorkingSetStat, error) {
+	host, hostErr := mem.GetStat()
+	if limit == Unlimited {
+		if hostErr != nil {
+			return mem.WorkingSetStat{Usage: usage}, hostErr
+		}
+		limit = host.Total
+	}
+	stat := mem.WorkingSetStat{Usage: usage, Limit: limit}
 
+	values := readCgroupKeyValues(memoryCgroupDir + "/memory.stat")
 	if cgroupVersion == 1 {
-		usage2, _ = readCpuacctUsage()
+		// total_ keys include the descendant cgroups, like v2 ones
+		stat.ActiveFile = values["total_active_file"]
+		stat.InactiveFile = values["total_inactive_file"]
+		stat.ActiveAnon = values["total_active_anon"]
+		stat.InactiveAnon = values["total_inactive_anon"]
+		stat.Dirty = values["total_dirty"]
+		stat.Writeback = values["total_writeback"]
 	} else {
-		usage2, _ = readCgroupV2CpuUsage()
+		stat.ActiveFile = values["active_file"]
+		stat.InactiveFile = values["inactive_file"]
+		stat.ActiveAnon = values["active_anon"]
+		stat.InactiveAnon = values["inactive_anon"]
+		stat.Dirty = values["file_dirty"]
+		stat.Writeback = values["file_writeback"]
+		stat.SlabReclaimable = values["slab_reclaimable"]
 	}
-
-	// uso total entre os dois samples
-	deltaUsage := usage2 - usage1
-	// delta time em nanos
-	deltaTime := seconds.Seconds() * 1e9
-
-	percent := (float64(deltaUsage) / deltaTime) * 100
-	return common.ParseFloat(fmt.Sprintf("%.2f", percent)), nil
+	if hostErr == nil {
+		stat.SwapFree = host.SwapFree
+	}
+	if free, ok := cgroupSwapFree(usage); ok && free < stat.SwapFree {
+		stat.SwapFree = free
+	}
+	return mem.CalculateWorkingSet(stat), nil
 }
 
-func readCpuacctUsage() (uint64, error) {
-	data, err := common.ReadFileNoStat(fmt.Sprintf("%s/cpuacct/cpuacct.usage", cgroupBasePath))
-	if err != nil {
-		return 0, err
+// cgroupSwapFree returns the swap the cgroup may still use, from
+// memory.swap.max and memory.swap.current on v2, or from the memsw limit and
+// usage minus the memory ones on v1. ok is false when swap is not limited,
+// or not accounted.
+func cgroupSwapFree(usage uint64) (free uint64, ok bool) {
+	var swapMax, swapCurrent uint64
+	if cgroupVersion == 1 {
+		memswLimit, err := readCgroupValue(memoryCgroupDir + "/memory.memsw.limit_in_bytes")
+		if err != nil || memswLimit == Unlimited {
+			return 0, false
+		}
+		limit, err := readCgroupValue(memoryCgroupDir + "/memory.limit_in_bytes")
+		if err != nil {
+			return 0, false
+		}
+		if memswLimit > limit {
+			swapMax = memswLimit - limit
+		}
+		if memswUsage, err := readCgroupValue(memoryCgroupDir + "/memory.memsw.usage_in_bytes"); err == nil && memswUsage > usage {
+			swapCurrent = memswUsage - usage
+		}
+	} else {
+		var err error
+		swapMax, err = readCgroupValue(memoryCgroupDir + "/memory.swap.max")
+		if err != nil || swapMax == Unlimited {
+			return 0, false
+		}
+		swapCurrent, _ = readCgroupValue(memoryCgroupDir + "/memory.swap.current")
+	}
+	if swapMax > swapCurrent {
+		return swapMax - swapCurrent, true
 	}
-	return common.ParseUint64(string(data)), nil
+	return 0, true
 }
 
-func readCgroupV2CpuUsage() (uint64, error) {
-	data, err := common.ReadFileNoStat(fmt.Sprintf("%s/cpu.stat", cgroupBasePath))
+// CpuPercent returns the CPU usage of the container over seconds, relative
+// to a single CPU. See CPUQuota for the usage against the CPUs the container
+// may use.
+func CpuPercent(seconds time.Duration) (float64, error) {
+	c, err := CPUQuota(seconds)
 	if err != nil {
 		return 0, err
 	}
-	lines := splitLines(string(data))
-	for _, line := range lines {
-		if hasPrefix(line, "usage_usec") {
-			fields := splitFields(line)
-			if len(fields) == 2 {
-				val, _ := strconv.ParseUint(fields[1], 10, 64)
-				return val * 1000, nil // converte para nanossegundos
-			}
-		}
-	}
-	return 0, errors.New("usage_usec not found in cpu.stat")
+	return common.ParseFloat(fmt.Sprintf("%.2f", c.UsagePercent)), nil
 }
 
 func detectCgroupVersion() (int, error) {
@@ -184,24 +210,12 @@ func detectCgroupVersion() (int, error) {
 		return 0, errors.New("only runs on Linux")
 	}
 
-	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err == nil {
-		return 2, nil
-	}
-	return 1, nil
-}
-
-func parseMemInfoTotal(meminfo string) uint64 {
-	lines := splitLines(meminfo)
-	for _, line := range lines {
-		if hasPrefix(line, "MemTotal:") {
-			fields := splitFields(line)
-			if len(fields) >= 2 {
-				val, _ := strconv.ParseUint(fields[1], 10, 64)
-				return val * 1024 // kB -> bytes
-			}
-		}
+	dir, version, err := CgroupDir(int32(os.Getpid()), "memory")
+	if err != nil {
+		return 0, err
 	}
-	return 0
+	memoryCgroupDir = dir
+	return version, nil
 }
 
 func splitLines(s string) []string {
@@ -211,7 +225,3 @@ func splitLines(s string) []string {
 func splitFields(s string) []string {
 	return strings.Fields(s)
 }
-
-func hasPrefix(s, prefix string) bool {
-	return strings.HasPrefix(s, prefix)
-}
@@ -0,0 +1,143 @@
+//go:build linux
+
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(filename), 0o755))
+		assert.Nil(t, os.WriteFile(filename, []byte(content), 0o644))
+	}
+}
+
+// useMemoryCgroup points the package at a fake memory cgroup for the test.
+func useMemoryCgroup(t *testing.T, version int, dir string) {
+	t.Helper()
+	savedVersion, savedErr, savedMemory := cgroupVersion, cgroupDetectErr, memoryCgroupDir
+	t.Cleanup(func() {
+		cgroupVersion, cgroupDetectErr, memoryCgroupDir = savedVersion, savedErr, savedMemory
+	})
+	cgroupVersion, cgroupDetectErr, memoryCgroupDir = version, nil, dir
+}
+
+// useHostSwap fakes a host with 4 GiB of free swap.
+func useHostSwap(t *testing.T) {
+	t.Helper()
+	proc := t.TempDir()
+	writeTestFiles(t, proc, map[string]string{
+		"meminfo": "MemTotal: 8388608 kB\nMemFree: 1048576 kB\nSwapTotal: 4194304 kB\nSwapFree: 4194304 kB\n",
+	})
+	t.Setenv("HOST_PROC", proc)
+}
+
+const (
+	mib = 1 << 20
+	gib = 1 << 30
+)
+
+func TestWorkingSetV2(t *testing.T) {
+	useHostSwap(t)
+	dir := t.TempDir()
+	useMemoryCgroup(t, 2, dir)
+	files := map[string]string{
+		"memory.current":      "536870912\n",
+		"memory.max":          "1073741824\n",
+		"memory.swap.max":     "0\n",
+		"memory.swap.current": "0\n",
+		"memory.stat": "anon 300000000\nfile 200000000\nactive_anon 104857600\ninactive_anon 209715200\n" +
+			"active_file 52428800\ninactive_file 104857600\nfile_dirty 1048576\nfile_writeback 0\nslab_reclaimable 4194304\n",
+	}
+	writeTestFiles(t, dir, files)
+
+	// memory.swap.max 0, the Kubernetes default, nothing can be swapped
+	ws, err := WorkingSet()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(512*mib), ws.Usage)
+	assert.Equal(t, uint64(gib), ws.Limit)
+	assert.Equal(t, uint64(412*mib), ws.WorkingSet)
+	assert.Equal(t, uint64(0), ws.SwapFree)
+	assert.Equal(t, uint64(0), ws.SwappableAnon)
+	assert.Equal(t, uint64(149*mib), ws.CleanCache)
+	assert.Equal(t, uint64(153*mib), ws.Reclaimable)
+
+	vm, err := VirtualMemory()
+	assert.Nil(t, err)
+	assert.Equal(t, ws.WorkingSet, vm.Used)
+	assert.Equal(t, ws.WorkingSet, vm.WorkingSet)
+
+	// 64 MiB left of a 128 MiB swap limit
+	writeTestFiles(t, dir, map[string]string{"memory.swap.max": "134217728\n", "memory.swap.current": "67108864\n"})
+	ws, err = WorkingSet()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(64*mib), ws.SwappableAnon)
+
+	// no swap limit, the free swap of the host
+	writeTestFiles(t, dir, map[string]string{"memory.swap.max": "max\n"})
+	ws, err = WorkingSet()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(4*gib), ws.SwapFree)
+	assert.Equal(t, uint64(200*mib), ws.SwappableAnon)
+
+	// no memory limit, the memory of the host read below HOST_PROC
+	writeTestFiles(t, dir, map[string]string{"memory.max": "max\n"})
+	ws, err = WorkingSet()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(8*gib), ws.Limit)
+	assert.Equal(t, uint64(412*mib), ws.WorkingSet)
+}
+
+func TestWorkingSetV1(t *testing.T) {
+	useHostSwap(t)
+	dir := t.TempDir()
+	useMemoryCgroup(t, 1, dir)
+	writeTestFiles(t, dir, map[string]string{
+		"memory.usage_in_bytes":       "536870912\n",
+		"memory.limit_in_bytes":       "1073741824\n",
+		"memory.memsw.usage_in_bytes": "570425344\n",  // 32 MiB swapped
+		"memory.memsw.limit_in_bytes": "1207959552\n", // 128 MiB of swap
+		// the local keys of the cgroup itself come after the total_ ones and
+		// must not replace them
+		"memory.stat": "total_active_anon 104857600\ntotal_inactive_anon 209715200\ntotal_active_file 52428800\ntotal_inactive_file 104857600\n" +
+			"rss 300000000\nactive_anon 0\ninactive_anon 1048576\nactive_file 0\ninactive_file 1048576\n",
+	})
+
+	ws, err := WorkingSet()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(gib), ws.Limit)
+	assert.Equal(t, uint64(412*mib), ws.WorkingSet)
+	assert.Equal(t, uint64(96*mib), ws.SwapFree)
+	assert.Equal(t, uint64(96*mib), ws.SwappableAnon)
+
+	// --memory-swap equal to --memory, no swap for the container
+	writeTestFiles(t, dir, map[string]string{"memory.memsw.limit_in_bytes": "1073741824\n"})
+	ws, err = WorkingSet()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), ws.SwappableAnon)
+
+	// swap accounting disabled, the free swap of the host
+	assert.Nil(t, os.Remove(filepath.Join(dir, "memory.memsw.limit_in_bytes")))
+	ws, err = WorkingSet()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(200*mib), ws.SwappableAnon)
+
+	// no limit, LONG_MAX rounded down to the page size, the memory of the host
+	writeTestFiles(t, dir, map[string]string{"memory.limit_in_bytes": "9223372036854771712\n"})
+	ws, err = WorkingSet()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(8*gib), ws.Limit)
+	assert.Equal(t, uint64(412*mib), ws.WorkingSet)
+
+	vm, err := VirtualMemory()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(8*gib), vm.Total)
+	assert.Equal(t, uint64(8*gib-412*mib), vm.Free)
+}
@@ -0,0 +1,156 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/ravoni4devs/syspector/cpu"
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// CPUQuotaStat is the CPU usage of the container over an interval measured
+// against what it is allowed to use.
+//
+// EffectiveCPUs is the smallest of the CFS quota (cpu.max, or
+// cpu.cfs_quota_us / cpu.cfs_period_us on v1), the number of CPUs of the
+// cpuset and the number of CPUs of the host. UsagePercent is relative to a
+// single CPU, as CpuPercent, so 2 busy cores give 200; QuotaPercent is
+// relative to EffectiveCPUs and stays within 0-100 like a host CPU percent.
+//
+// ThrottledPercent is the share of the enforcement periods of the interval
+// in which the container was throttled.
+type CPUQuotaStat struct {
+	Interval         time.Duration `json:"interval"`
+	Quota            int64         `json:"quota"`
+	Period           uint64        `json:"period"`
+	CPUSet           cpu.CPUSet    `json:"cpuset"`
+	EffectiveCPUs    float64       `json:"effectiveCpus"`
+	UsagePercent     float64       `json:"usagePercent"`
+	QuotaPercent     float64       `json:"quotaPercent"`
+	Periods          uint64        `json:"periods"`
+	ThrottledPeriods uint64        `json:"throttledPeriods"`
+	ThrottledPercent float64       `json:"throttledPercent"`
+	ThrottledTime    time.Duration `json:"throttledTime"`
+}
+
+func (c CPUQuotaStat) String() string {
+	s, _ := json.Marshal(c)
+	return string(s)
+}
+
+// EffectiveCPUs returns the number of CPUs the container can use, which can
+// be fractional with a CFS quota.
+func EffectiveCPUs() (float64, error) {
+	if cgroupDetectErr != nil {
+		return 0, cgroupDetectErr
+	}
+	stat, set, err := readCPULimits(int32(os.Getpid()))
+	if err != nil {
+		return 0, err
+	}
+	return effectiveCPUs(stat.Quota, stat.Period, set), nil
+}
+
+// CPUQuota measures the CPU usage and throttling of the container over
+// interval.
+func CPUQuota(interval time.Duration) (CPUQuotaStat, error) {
+	var ret CPUQuotaStat
+	if cgroupDetectErr != nil {
+		return ret, cgroupDetectErr
+	}
+	pid := int32(os.Getpid())
+
+	start := time.Now()
+	s1, _, err := readCPULimits(pid)
+	if err != nil {
+		return ret, err
+	}
+
+	time.Sleep(interval)
+
+	s2, set, err := readCPULimits(pid)
+	if err != nil {
+		return ret, err
+	}
+	elapsed := time.Since(start)
+
+	ret = CPUQuotaStat{
+		Interval:      elapsed,
+		Quota:         s2.Quota,
+		Period:        s2.Period,
+		CPUSet:        set,
+		EffectiveCPUs: effectiveCPUs(s2.Quota, s2.Period, set),
+	}
+	if s2.UsageUsec >= s1.UsageUsec && elapsed > 0 {
+		ret.UsagePercent = float64(s2.UsageUsec-s1.UsageUsec) / float64(elapsed.Microseconds()) * 100
+	}
+	if ret.EffectiveCPUs > 0 {
+		ret.QuotaPercent = ret.UsagePercent / ret.EffectiveCPUs
+	}
+	if s2.NrPeriods >= s1.NrPeriods && s2.NrThrottled >= s1.NrThrottled {
+		ret.Periods = s2.NrPeriods - s1.NrPeriods
+		ret.ThrottledPeriods = s2.NrThrottled - s1.NrThrottled
+		if ret.Periods > 0 {
+			ret.ThrottledPercent = float64(ret.ThrottledPeriods) / float64(ret.Periods) * 100
+		}
+	}
+	if s2.ThrottledUsec >= s1.ThrottledUsec {
+		ret.ThrottledTime = time.Duration(s2.ThrottledUsec-s1.ThrottledUsec) * time.Microsecond
+	}
+	return ret, nil
+}
+
+// readCPULimits returns the CPU counters, quota and cpuset of the cgroup
+// of the process.
+func readCPULimits(pid int32) (CgroupCPUStat, cpu.CPUSet, error) {
+	cpuDir, version, err := CgroupDir(pid, "cpu")
+	if err != nil {
+		return CgroupCPUStat{}, nil, err
+	}
+
+	if version == 2 {
+		return readCgroupV2CPU(cpuDir), readCgroupCPUSet(cpuDir + "/cpuset.cpus.effective"), nil
+	}
+
+	cpuacctDir, v, err := CgroupDir(pid, "cpuacct")
+	if err != nil || v != 1 {
+		cpuacctDir = cpuDir
+	}
+	var set cpu.CPUSet
+	if cpusetDir, v, err := CgroupDir(pid, "cpuset"); err == nil && v == 1 {
+		set = readCgroupCPUSet(cpusetDir + "/cpuset.effective_cpus")
+		if len(set) == 0 {
+			set = readCgroupCPUSet(cpusetDir + "/cpuset.cpus")
+		}
+	}
+	return readCgroupV1CPU(cpuDir, cpuacctDir), set, nil
+}
+
+func readCgroupCPUSet(filename string) cpu.CPUSet {
+	data, err := common.ReadFileNoStat(filename)
+	if err != nil {
+		return nil
+	}
+	set, err := cpu.ParseCPUSet(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil
+	}
+	return set
+}
+
+func effectiveCPUs(quota int64, period uint64, set cpu.CPUSet) float64 {
+	n := float64(runtime.NumCPU())
+	if len(set) > 0 && float64(len(set)) < n {
+		n = float64(len(set))
+	}
+	if quota > 0 && period > 0 {
+		if q := float64(quota) / float64(period); q < n {
+			n = q
+		}
+	}
+	return common.ParseFloat(fmt.Sprintf("%.2f", n))
+}
@@ -0,0 +1,119 @@
+//go:build linux
+
+package docker
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/ravoni4devs/syspector/cpu"
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func TestEffectiveCPUs(t *testing.T) {
+	// the host bounds every limit, the tests may run on a single CPU
+	host := float64(runtime.NumCPU())
+	for _, tt := range []struct {
+		name   string
+		quota  int64
+		period uint64
+		set    cpu.CPUSet
+		want   float64
+	}{
+		{"unlimited", -1, 100000, nil, host},
+		{"no period", 50000, 0, nil, host},
+		{"half a CPU", 50000, 100000, nil, 0.5},
+		{"fractional", 150000, 100000, nil, math.Min(1.5, host)},
+		{"cpuset narrower than the quota", 400000, 100000, cpu.CPUSet{0}, 1},
+		{"quota narrower than the cpuset", 50000, 100000, cpu.CPUSet{0, 1, 2, 3}, 0.5},
+		{"rounded", 100000, 300000, nil, 0.33},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, effectiveCPUs(tt.quota, tt.period, tt.set))
+		})
+	}
+}
+
+func TestReadCPULimitsV2(t *testing.T) {
+	sys := fakeCgroupHost(t, "0::/app.slice\n", mountinfoV2)
+	writeTestFiles(t, sys, map[string]string{
+		"fs/cgroup/app.slice/cpu.max":               "max 100000\n",
+		"fs/cgroup/app.slice/cpu.stat":              "usage_usec 2500000\nnr_periods 10\nnr_throttled 2\nthrottled_usec 1000\n",
+		"fs/cgroup/app.slice/cpuset.cpus.effective": "0\n",
+	})
+
+	stat, set, err := readCPULimits(42)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-1), stat.Quota)
+	assert.Equal(t, uint64(100000), stat.Period)
+	assert.Equal(t, uint64(2500000), stat.UsageUsec)
+	assert.DeepEqual(t, cpu.CPUSet{0}, set)
+	assert.Equal(t, 1.0, effectiveCPUs(stat.Quota, stat.Period, set))
+
+	writeTestFiles(t, sys, map[string]string{
+		"fs/cgroup/app.slice/cpu.max":               "150000 100000\n",
+		"fs/cgroup/app.slice/cpuset.cpus.effective": "0-3\n",
+	})
+	stat, set, err = readCPULimits(42)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(150000), stat.Quota)
+	assert.Equal(t, math.Min(1.5, float64(runtime.NumCPU())), effectiveCPUs(stat.Quota, stat.Period, set))
+}
+
+func TestReadCPULimitsV1(t *testing.T) {
+	// kernels since 6.0 show favordynmods among the super options
+	const mountinfo = `22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+32 22 0:28 /docker/abc /sys/fs/cgroup/cpu,cpuacct rw,nosuid - cgroup cgroup rw,cpu,cpuacct
+33 22 0:29 /docker/abc /sys/fs/cgroup/cpuset rw,nosuid - cgroup cgroup rw,favordynmods,cpuset
+`
+	const cgroup = `11:cpu,cpuacct:/docker/abc
+4:cpuset:/docker/abc
+`
+	sys := fakeCgroupHost(t, cgroup, mountinfo)
+	writeTestFiles(t, sys, map[string]string{
+		"fs/cgroup/cpu,cpuacct/cpu.cfs_quota_us":  "-1\n",
+		"fs/cgroup/cpu,cpuacct/cpu.cfs_period_us": "100000\n",
+		"fs/cgroup/cpu,cpuacct/cpuacct.usage":     "2500000000\n",
+		"fs/cgroup/cpuset/cpuset.effective_cpus":  "0\n",
+	})
+
+	stat, set, err := readCPULimits(42)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-1), stat.Quota)
+	assert.Equal(t, uint64(100000), stat.Period)
+	assert.Equal(t, uint64(2500000), stat.UsageUsec)
+	assert.DeepEqual(t, cpu.CPUSet{0}, set)
+	assert.Equal(t, 1.0, effectiveCPUs(stat.Quota, stat.Period, set))
+}
+
+func TestCPUQuota(t *testing.T) {
+	sys := fakeCgroupHost(t, "0::/app.slice\n", mountinfoV2)
+	writeTestFiles(t, sys, map[string]string{
+		"fs/cgroup/app.slice/cpu.max":               "50000 100000\n",
+		"fs/cgroup/app.slice/cpu.stat":              "usage_usec 1000\nnr_periods 10\nnr_throttled 5\n",
+		"fs/cgroup/app.slice/cpuset.cpus.effective": "0-1\n",
+	})
+	// CPUQuota reads the cgroup of the running process
+	writeTestFiles(t, filepath.Dir(sys), map[string]string{
+		filepath.Join("proc", strconv.Itoa(os.Getpid()), "cgroup"): "0::/app.slice\n",
+	})
+	useMemoryCgroup(t, 2, filepath.Join(sys, "fs/cgroup/app.slice"))
+
+	q, err := CPUQuota(0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(50000), q.Quota)
+	assert.Equal(t, uint64(100000), q.Period)
+	assert.DeepEqual(t, cpu.CPUSet{0, 1}, q.CPUSet)
+	assert.Equal(t, 0.5, q.EffectiveCPUs)
+	// the counters did not move between the samples
+	assert.Equal(t, 0.0, q.UsagePercent)
+	assert.Equal(t, uint64(0), q.Periods)
+
+	n, err := EffectiveCPUs()
+	assert.Nil(t, err)
+	assert.Equal(t, 0.5, n)
+}
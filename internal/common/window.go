@@ -0,0 +1,51 @@
+package common
+
+// SustainedRatio is the share of the samples of a full Window that must
+// match for a condition to be sustained rather than a spike.
+const SustainedRatio = 0.75
+
+// Window keeps the last size samples added to it. It is not safe for
+// concurrent use.
+type Window[T any] struct {
+	size    int
+	samples []T
+	next    int
+}
+
+// NewWindow returns an empty window of size samples, at least one.
+func NewWindow[T any](size int) *Window[T] {
+	if size < 1 {
+		size = 1
+	}
+	return &Window[T]{size: size, samples: make([]T, 0, size)}
+}
+
+// Add records v, replacing the oldest sample once the window is full.
+func (w *Window[T]) Add(v T) {
+	if len(w.samples) < w.size {
+		w.samples = append(w.samples, v)
+	} else {
+		w.samples[w.next] = v
+	}
+	w.next = (w.next + 1) % w.size
+}
+
+// Samples returns the samples in the window, in no particular order.
+func (w *Window[T]) Samples() []T {
+	return w.samples
+}
+
+// Sustained reports whether the window is full and at least SustainedRatio
+// of its samples match.
+func (w *Window[T]) Sustained(match func(T) bool) bool {
+	if len(w.samples) < w.size {
+		return false
+	}
+	count := 0
+	for _, s := range w.samples {
+		if match(s) {
+			count++
+		}
+	}
+	return float64(count) >= float64(w.size)*SustainedRatio
+}
@@ -20,9 +20,21 @@ func TestDataJoin(f string) string {
 	return filepath.Join(GetTestDataPath(), f)
 }
 
+// GetTestDataPath returns internal/test/testdata of the module, found from
+// the directory of the running test, which is its package directory.
 func GetTestDataPath() string {
 	current, _ := os.Getwd()
-	parent := filepath.Dir(current)
-	root := filepath.Dir(parent)
-	return filepath.Join(root, "test", "testdata")
+	root := current
+	for {
+		if _, err := os.Stat(filepath.Join(root, "go.mod")); err == nil {
+			break
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			root = current
+			break
+		}
+		root = parent
+	}
+	return filepath.Join(root, "internal", "test", "testdata")
 }
@@ -0,0 +1,199 @@
+//go:build linux
+
+package mem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// ZoneFragmentationStat describes the free memory of a zone, split by
+// allocation order, as found in /proc/buddyinfo. Slices are indexed by
+// order: FreeBlocks[3] is the number of free blocks of 2^3 pages.
+//
+// UnusableIndex[j] is the fraction of free memory that cannot satisfy an
+// allocation of order j (0 means every free page is usable).
+// FragmentationIndex[j] follows the kernel extfrag index: towards 0 an
+// order j allocation fails for lack of memory, towards 1 because of
+// fragmentation, and -1 means it would succeed.
+//
+// MigrateTypes and PageblockCounts come from /proc/pagetypeinfo, which is
+// only readable by root; they are empty otherwise.
+type ZoneFragmentationStat struct {
+	Node               int                 `json:"node"`
+	Zone               string              `json:"zone"`
+	FreeBlocks         []uint64            `json:"freeBlocks"`
+	FreePages          uint64              `json:"freePages"`
+	UnusableIndex      []float64           `json:"unusableIndex"`
+	FragmentationIndex []float64           `json:"fragmentationIndex"`
+	LargestFreeOrder   int                 `json:"largestFreeOrder"`
+	LargestFreeBlock   uint64              `json:"largestFreeBlock"`
+	MigrateTypes       map[string][]uint64 `json:"migrateTypes,omitempty"`
+	PageblockCounts    map[string]uint64   `json:"pageblockCounts,omitempty"`
+}
+
+func (z ZoneFragmentationStat) String() string {
+	s, _ := json.Marshal(z)
+	return string(s)
+}
+
+// Fragmentation returns the free memory fragmentation of every zone.
+func Fragmentation() ([]ZoneFragmentationStat, error) {
+	return FragmentationWithContext(context.Background())
+}
+
+func FragmentationWithContext(ctx context.Context) ([]ZoneFragmentationStat, error) {
+	lines, err := common.ReadLines(common.HostProcWithContext(ctx, "buddyinfo"))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []ZoneFragmentationStat
+	index := make(map[string]int)
+	for _, line := range lines {
+		node, zone, counts, err := parseZoneLine(strings.Fields(line), 4)
+		if err != nil {
+			continue
+		}
+		z := ZoneFragmentationStat{Node: node, Zone: zone, FreeBlocks: counts}
+		calculateFragmentation(&z, uint64(os.Getpagesize()))
+		index[zoneKey(node, zone)] = len(ret)
+		ret = append(ret, z)
+	}
+
+	// pagetypeinfo is optional, buddyinfo already has everything needed
+	// for the indexes.
+	if lines, err := common.ReadLines(common.HostProcWithContext(ctx, "pagetypeinfo")); err == nil {
+		parsePagetypeinfo(lines, ret, index)
+	}
+	return ret, nil
+}
+
+func zoneKey(node int, zone string) string {
+	return strconv.Itoa(node) + "/" + zone
+}
+
+// parseZoneLine parses "Node 0, zone Normal <counts...>" style lines whose
+// counts start at field first.
+func parseZoneLine(fields []string, first int) (int, string, []uint64, error) {
+	if len(fields) < first || fields[0] != "Node" || fields[2] != "zone" {
+		return 0, "", nil, fmt.Errorf("not a zone line: %q", strings.Join(fields, " "))
+	}
+	node, err := strconv.Atoi(strings.TrimSuffix(fields[1], ","))
+	if err != nil {
+		return 0, "", nil, err
+	}
+	zone := strings.TrimSuffix(fields[3], ",")
+	counts := make([]uint64, 0, len(fields)-first)
+	for _, f := range fields[first:] {
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return 0, "", nil, err
+		}
+		counts = append(counts, v)
+	}
+	return node, zone, counts, nil
+}
+
+func parsePagetypeinfo(lines []string, zones []ZoneFragmentationStat, index map[string]int) {
+	var blockTypes []string
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.HasPrefix(line, "Number of blocks type") {
+			blockTypes = fields[4:]
+			continue
+		}
+		if fields[0] != "Node" {
+			continue
+		}
+
+		if len(fields) > 5 && fields[4] == "type" {
+			// Node 0, zone Normal, type Movable <counts per order...>
+			node, zone, counts, err := parseZoneLine(append(fields[:4:4], fields[6:]...), 4)
+			if err != nil {
+				continue
+			}
+			i, ok := index[zoneKey(node, zone)]
+			if !ok {
+				continue
+			}
+			if zones[i].MigrateTypes == nil {
+				zones[i].MigrateTypes = make(map[string][]uint64)
+			}
+			zones[i].MigrateTypes[fields[5]] = counts
+			continue
+		}
+
+		// Node 0, zone Normal <pageblocks per type...>
+		node, zone, counts, err := parseZoneLine(fields, 4)
+		if err != nil || blockTypes == nil {
+			continue
+		}
+		i, ok := index[zoneKey(node, zone)]
+		if !ok {
+			continue
+		}
+		zones[i].PageblockCounts = make(map[string]uint64, len(counts))
+		for j, c := range counts {
+			if j < len(blockTypes) {
+				zones[i].PageblockCounts[blockTypes[j]] = c
+			}
+		}
+	}
+}
+
+// calculateFragmentation fills the derived fields of z from FreeBlocks,
+// following mm/vmstat.c (fill_contig_page_info and __fragmentation_index)
+// and the unusable free space index of Documentation/admin-guide/sysctl/vm.
+func calculateFragmentation(z *ZoneFragmentationStat, pageSize uint64) {
+	orders := len(z.FreeBlocks)
+	z.UnusableIndex = make([]float64, orders)
+	z.FragmentationIndex = make([]float64, orders)
+	z.LargestFreeOrder = -1
+
+	var totalBlocks uint64
+	for order, blocks := range z.FreeBlocks {
+		z.FreePages += blocks << order
+		totalBlocks += blocks
+		if blocks > 0 {
+			z.LargestFreeOrder = order
+		}
+	}
+	if z.LargestFreeOrder >= 0 {
+		z.LargestFreeBlock = (uint64(1) << z.LargestFreeOrder) * pageSize
+	}
+
+	for order := range z.FreeBlocks {
+		// free pages held in blocks large enough for this order
+		var suitablePages, suitableBlocks uint64
+		for i := order; i < orders; i++ {
+			suitablePages += z.FreeBlocks[i] << i
+			suitableBlocks += z.FreeBlocks[i] << (i - order)
+		}
+
+		if z.FreePages == 0 {
+			z.UnusableIndex[order] = 1
+		} else {
+			z.UnusableIndex[order] = float64(z.FreePages-suitablePages) / float64(z.FreePages)
+		}
+
+		switch {
+		case totalBlocks == 0:
+			z.FragmentationIndex[order] = 0
+		case suitableBlocks > 0:
+			z.FragmentationIndex[order] = -1
+		default:
+			requested := float64(uint64(1) << order)
+			z.FragmentationIndex[order] = 1 - (1+float64(z.FreePages)/requested)/float64(totalBlocks)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+//go:build linux
+
+package mem
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+const procBuddyinfo = `Node 0, zone      DMA      1      1      1      0      2
+Node 0, zone    DMA32      4      2      1      0      0
+Node 1, zone   Normal      0      0      0      0      0
+`
+
+const procPagetypeinfo = `Page block order: 9
+Pages per block:  512
+
+Free pages count per migrate type at order       0      1      2      3      4
+Node    0, zone      DMA, type    Unmovable      1      0      0      0      0
+Node    0, zone      DMA, type      Movable      0      1      1      0      2
+Node    0, zone    DMA32, type      Movable      4      2      1      0      0
+
+Number of blocks type     Unmovable      Movable  Reclaimable   HighAtomic      Isolate
+Node 0, zone      DMA            1            7            0            0            0
+Node 0, zone    DMA32          100          900            3            0            0
+`
+
+func TestFragmentation(t *testing.T) {
+	proc := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(proc, "buddyinfo"), []byte(procBuddyinfo), 0o644))
+	t.Setenv("HOST_PROC", proc)
+
+	zones, err := Fragmentation()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(zones))
+
+	dma32 := zones[1]
+	assert.Equal(t, 0, dma32.Node)
+	assert.Equal(t, "DMA32", dma32.Zone)
+	assert.DeepEqual(t, []uint64{4, 2, 1, 0, 0}, dma32.FreeBlocks)
+	assert.Equal(t, uint64(12), dma32.FreePages)
+	assert.Equal(t, 2, dma32.LargestFreeOrder)
+	assert.Equal(t, uint64(4*os.Getpagesize()), dma32.LargestFreeBlock)
+	assert.Equal(t, 0.0, dma32.UnusableIndex[0])
+	assert.Equal(t, 4.0/12, dma32.UnusableIndex[1])
+	assert.Equal(t, 1.0, dma32.UnusableIndex[3])
+	assert.Equal(t, -1.0, dma32.FragmentationIndex[2])
+	// mm/vmstat.c: 1000 - (1000 + 12*1000/8) / 7 = 643
+	assert.Equal(t, 0.643, math.Round(dma32.FragmentationIndex[3]*1000)/1000)
+	assert.Nil(t, dma32.MigrateTypes)
+
+	empty := zones[2]
+	assert.Equal(t, 1, empty.Node)
+	assert.Equal(t, -1, empty.LargestFreeOrder)
+	assert.Equal(t, 1.0, empty.UnusableIndex[0])
+	assert.Equal(t, 0.0, empty.FragmentationIndex[4])
+}
+
+func TestParsePagetypeinfo(t *testing.T) {
+	zones := []ZoneFragmentationStat{{Node: 0, Zone: "DMA"}, {Node: 0, Zone: "DMA32"}}
+	index := map[string]int{zoneKey(0, "DMA"): 0, zoneKey(0, "DMA32"): 1}
+	parsePagetypeinfo(strings.Split(procPagetypeinfo, "\n"), zones, index)
+
+	assert.DeepEqual(t, map[string][]uint64{
+		"Unmovable": {1, 0, 0, 0, 0},
+		"Movable":   {0, 1, 1, 0, 2},
+	}, zones[0].MigrateTypes)
+	assert.DeepEqual(t, []uint64{4, 2, 1, 0, 0}, zones[1].MigrateTypes["Movable"])
+	assert.DeepEqual(t, map[string]uint64{
+		"Unmovable":   100,
+		"Movable":     900,
+		"Reclaimable": 3,
+		"HighAtomic":  0,
+		"Isolate":     0,
+	}, zones[1].PageblockCounts)
+}
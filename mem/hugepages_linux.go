@@ -0,0 +1,190 @@
+//go:build linux
+
+package mem
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// HugePagePoolStat is a hugetlb pool of /sys/kernel/mm/hugepages. PageSize
+// is in bytes, the other values are numbers of huge pages.
+type HugePagePoolStat struct {
+	PageSize   uint64             `json:"pageSize"`
+	Total      uint64             `json:"total"`
+	Free       uint64             `json:"free"`
+	Reserved   uint64             `json:"reserved"`
+	Surplus    uint64             `json:"surplus"`
+	Overcommit uint64             `json:"overcommit"`
+	Nodes      []HugePageNodeStat `json:"nodes,omitempty"`
+}
+
+// HugePageNodeStat is the share of a hugetlb pool allocated on a NUMA node.
+type HugePageNodeStat struct {
+	Node    int    `json:"node"`
+	Total   uint64 `json:"total"`
+	Free    uint64 `json:"free"`
+	Surplus uint64 `json:"surplus"`
+}
+
+// THPStat holds the Transparent Huge Page configuration of
+// /sys/kernel/mm/transparent_hugepage. Enabled, Defrag and ShmemEnabled
+// are the selected modes, e.g. "madvise". SizeEnabled holds the per size
+// (multi-size THP, Linux 6.8+) modes keyed by page size in bytes.
+type THPStat struct {
+	Enabled      string            `json:"enabled"`
+	Defrag       string            `json:"defrag"`
+	ShmemEnabled string            `json:"shmemEnabled,omitempty"`
+	UseZeroPage  bool              `json:"useZeroPage"`
+	PMDSize      uint64            `json:"pmdSize,omitempty"`
+	SizeEnabled  map[uint64]string `json:"sizeEnabled,omitempty"`
+	Khugepaged   KhugepagedStat    `json:"khugepaged"`
+}
+
+type KhugepagedStat struct {
+	Defrag              bool   `json:"defrag"`
+	PagesToScan         uint64 `json:"pagesToScan"`
+	PagesCollapsed      uint64 `json:"pagesCollapsed"`
+	FullScans           uint64 `json:"fullScans"`
+	ScanSleepMillisecs  uint64 `json:"scanSleepMillisecs"`
+	AllocSleepMillisecs uint64 `json:"allocSleepMillisecs"`
+	MaxPtesNone         uint64 `json:"maxPtesNone"`
+}
+
+func (h HugePagePoolStat) String() string {
+	s, _ := json.Marshal(h)
+	return string(s)
+}
+
+func (t THPStat) String() string {
+	s, _ := json.Marshal(t)
+	return string(s)
+}
+
+// HugePagePools returns every hugetlb pool, e.g. 2MB and 1GB, sorted by page
+// size, together with its per NUMA node split.
+func HugePagePools() ([]HugePagePoolStat, error) {
+	return HugePagePoolsWithContext(context.Background())
+}
+
+func HugePagePoolsWithContext(ctx context.Context) ([]HugePagePoolStat, error) {
+	dirs, err := filepath.Glob(common.HostSysWithContext(ctx, "kernel/mm/hugepages/hugepages-*kB"))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []HugePagePoolStat
+	for _, dir := range dirs {
+		size, ok := parseHugePageDir(filepath.Base(dir))
+		if !ok {
+			continue
+		}
+		pool := HugePagePoolStat{PageSize: size}
+		pool.Total, _ = readSysUint(filepath.Join(dir, "nr_hugepages"))
+		pool.Free, _ = readSysUint(filepath.Join(dir, "free_hugepages"))
+		pool.Reserved, _ = readSysUint(filepath.Join(dir, "resv_hugepages"))
+		pool.Surplus, _ = readSysUint(filepath.Join(dir, "surplus_hugepages"))
+		pool.Overcommit, _ = readSysUint(filepath.Join(dir, "nr_overcommit_hugepages"))
+
+		nodeDirs, _ := filepath.Glob(common.HostSysWithContext(ctx, "devices/system/node/node[0-9]*/hugepages", filepath.Base(dir)))
+		for _, nodeDir := range nodeDirs {
+			node, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(filepath.Dir(filepath.Dir(nodeDir))), "node"))
+			if err != nil {
+				continue
+			}
+			n := HugePageNodeStat{Node: node}
+			n.Total, _ = readSysUint(filepath.Join(nodeDir, "nr_hugepages"))
+			n.Free, _ = readSysUint(filepath.Join(nodeDir, "free_hugepages"))
+			n.Surplus, _ = readSysUint(filepath.Join(nodeDir, "surplus_hugepages"))
+			pool.Nodes = append(pool.Nodes, n)
+		}
+		sort.Slice(pool.Nodes, func(i, j int) bool { return pool.Nodes[i].Node < pool.Nodes[j].Node })
+
+		ret = append(ret, pool)
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].PageSize < ret[j].PageSize })
+	return ret, nil
+}
+
+// TransparentHugePages returns the THP configuration and khugepaged scan
+// statistics.
+func TransparentHugePages() (*THPStat, error) {
+	return TransparentHugePagesWithContext(context.Background())
+}
+
+func TransparentHugePagesWithContext(ctx context.Context) (*THPStat, error) {
+	dir := common.HostSysWithContext(ctx, "kernel/mm/transparent_hugepage")
+	enabled, err := readSelectedMode(filepath.Join(dir, "enabled"))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &THPStat{Enabled: enabled}
+	ret.Defrag, _ = readSelectedMode(filepath.Join(dir, "defrag"))
+	ret.ShmemEnabled, _ = readSelectedMode(filepath.Join(dir, "shmem_enabled"))
+	if v, err := readSysUint(filepath.Join(dir, "use_zero_page")); err == nil {
+		ret.UseZeroPage = v == 1
+	}
+	ret.PMDSize, _ = readSysUint(filepath.Join(dir, "hpage_pmd_size"))
+
+	sizeDirs, _ := filepath.Glob(filepath.Join(dir, "hugepages-*kB"))
+	for _, sizeDir := range sizeDirs {
+		size, ok := parseHugePageDir(filepath.Base(sizeDir))
+		if !ok {
+			continue
+		}
+		mode, err := readSelectedMode(filepath.Join(sizeDir, "enabled"))
+		if err != nil {
+			continue
+		}
+		if ret.SizeEnabled == nil {
+			ret.SizeEnabled = make(map[uint64]string)
+		}
+		ret.SizeEnabled[size] = mode
+	}
+
+	khugepaged := filepath.Join(dir, "khugepaged")
+	if v, err := readSysUint(filepath.Join(khugepaged, "defrag")); err == nil {
+		ret.Khugepaged.Defrag = v == 1
+	}
+	ret.Khugepaged.PagesToScan, _ = readSysUint(filepath.Join(khugepaged, "pages_to_scan"))
+	ret.Khugepaged.PagesCollapsed, _ = readSysUint(filepath.Join(khugepaged, "pages_collapsed"))
+	ret.Khugepaged.FullScans, _ = readSysUint(filepath.Join(khugepaged, "full_scans"))
+	ret.Khugepaged.ScanSleepMillisecs, _ = readSysUint(filepath.Join(khugepaged, "scan_sleep_millisecs"))
+	ret.Khugepaged.AllocSleepMillisecs, _ = readSysUint(filepath.Join(khugepaged, "alloc_sleep_millisecs"))
+	ret.Khugepaged.MaxPtesNone, _ = readSysUint(filepath.Join(khugepaged, "max_ptes_none"))
+	return ret, nil
+}
+
+// parseHugePageDir returns the page size in bytes of a "hugepages-2048kB"
+// directory name.
+func parseHugePageDir(name string) (uint64, bool) {
+	kb := strings.TrimSuffix(strings.TrimPrefix(name, "hugepages-"), "kB")
+	v, err := strconv.ParseUint(kb, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v * 1024, true
+}
+
+// readSelectedMode returns the bracketed choice of a sysfs mode file such as
+// "always [madvise] never".
+func readSelectedMode(filename string) (string, error) {
+	lines, err := common.ReadLines(filename)
+	if err != nil {
+		return "", err
+	}
+	for _, field := range strings.Fields(strings.Join(lines, " ")) {
+		if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+			return strings.Trim(field, "[]"), nil
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, " ")), nil
+}
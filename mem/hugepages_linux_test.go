@@ -0,0 +1,116 @@
+//go:build linux
+
+package mem
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func TestParseHugePageDir(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		size uint64
+		ok   bool
+	}{
+		{"hugepages-2048kB", 2 << 20, true},
+		{"hugepages-1048576kB", 1 << 30, true},
+		{"hugepages-64kB", 64 << 10, true},
+		{"hugepages-2MB", 0, false},
+		{"hugepages-", 0, false},
+		{"enabled", 0, false},
+	} {
+		size, ok := parseHugePageDir(tt.name)
+		assert.Equal(t, tt.ok, ok)
+		assert.Equal(t, tt.size, size)
+	}
+}
+
+func TestReadSelectedMode(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		content, mode string
+	}{
+		{"always [madvise] never\n", "madvise"},
+		{"[always] madvise never\n", "always"},
+		{"always defer defer+madvise [madvise] never\n", "madvise"},
+		{"always within_size advise [never] deny force\n", "never"},
+		// files without a selection, e.g. the value of a single mode
+		{"inherit\n", "inherit"},
+	} {
+		filename := filepath.Join(dir, "enabled")
+		writeTestFile(t, filename, tt.content)
+		mode, err := readSelectedMode(filename)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.mode, mode)
+	}
+
+	_, err := readSelectedMode(filepath.Join(dir, "missing"))
+	assert.NotNil(t, err)
+}
+
+func TestHugePagePools(t *testing.T) {
+	sys := t.TempDir()
+	t.Setenv("HOST_SYS", sys)
+	for name, content := range map[string]string{
+		"kernel/mm/hugepages/hugepages-1048576kB/nr_hugepages":                "2\n",
+		"kernel/mm/hugepages/hugepages-1048576kB/free_hugepages":              "1\n",
+		"kernel/mm/hugepages/hugepages-2048kB/nr_hugepages":                   "512\n",
+		"kernel/mm/hugepages/hugepages-2048kB/free_hugepages":                 "500\n",
+		"kernel/mm/hugepages/hugepages-2048kB/resv_hugepages":                 "4\n",
+		"kernel/mm/hugepages/hugepages-2048kB/surplus_hugepages":              "0\n",
+		"kernel/mm/hugepages/hugepages-2048kB/nr_overcommit_hugepages":        "16\n",
+		"devices/system/node/node1/hugepages/hugepages-2048kB/nr_hugepages":   "256\n",
+		"devices/system/node/node1/hugepages/hugepages-2048kB/free_hugepages": "250\n",
+		"devices/system/node/node0/hugepages/hugepages-2048kB/nr_hugepages":   "256\n",
+		"devices/system/node/node0/hugepages/hugepages-2048kB/free_hugepages": "250\n",
+	} {
+		writeTestFile(t, filepath.Join(sys, name), content)
+	}
+
+	pools, err := HugePagePools()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(pools))
+	assert.DeepEqual(t, HugePagePoolStat{
+		PageSize: 2 << 20, Total: 512, Free: 500, Reserved: 4, Overcommit: 16,
+		Nodes: []HugePageNodeStat{{Node: 0, Total: 256, Free: 250}, {Node: 1, Total: 256, Free: 250}},
+	}, pools[0])
+	assert.Equal(t, uint64(1<<30), pools[1].PageSize)
+	assert.Equal(t, uint64(2), pools[1].Total)
+	assert.Equal(t, 0, len(pools[1].Nodes))
+}
+
+func TestTransparentHugePages(t *testing.T) {
+	sys := t.TempDir()
+	t.Setenv("HOST_SYS", sys)
+	thp := filepath.Join(sys, "kernel/mm/transparent_hugepage")
+	for name, content := range map[string]string{
+		"enabled":                         "always [madvise] never\n",
+		"defrag":                          "always defer defer+madvise [madvise] never\n",
+		"shmem_enabled":                   "always within_size advise [never] deny force\n",
+		"use_zero_page":                   "1\n",
+		"hpage_pmd_size":                  "2097152\n",
+		"hugepages-64kB/enabled":          "always [inherit] madvise never\n",
+		"khugepaged/defrag":               "1\n",
+		"khugepaged/pages_to_scan":        "4096\n",
+		"khugepaged/pages_collapsed":      "12\n",
+		"khugepaged/max_ptes_none":        "511\n",
+		"khugepaged/scan_sleep_millisecs": "10000\n",
+	} {
+		writeTestFile(t, filepath.Join(thp, name), content)
+	}
+
+	stat, err := TransparentHugePages()
+	assert.Nil(t, err)
+	assert.Equal(t, "madvise", stat.Enabled)
+	assert.Equal(t, "madvise", stat.Defrag)
+	assert.Equal(t, "never", stat.ShmemEnabled)
+	assert.Equal(t, true, stat.UseZeroPage)
+	assert.Equal(t, uint64(2<<20), stat.PMDSize)
+	assert.DeepEqual(t, map[uint64]string{64 << 10: "inherit"}, stat.SizeEnabled)
+	assert.Equal(t, KhugepagedStat{
+		Defrag: true, PagesToScan: 4096, PagesCollapsed: 12, ScanSleepMillisecs: 10000, MaxPtesNone: 511,
+	}, stat.Khugepaged)
+}
@@ -0,0 +1,187 @@
+//go:build linux
+
+package mem
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// KSMStat holds the Kernel Samepage Merging state of
+// /sys/kernel/mm/ksm. Page counters are numbers of pages.
+//
+// PagesShared is the number of deduplicated pages in use and PagesSharing
+// the number of additional sites sharing them. ZeroPages are the pages
+// merged with the zero page when UseZeroPages is set, so Saved, in bytes,
+// is PagesSharing plus ZeroPages pages. GeneralProfit (Linux 6.1+) is the
+// kernel's own estimate, which also accounts for the rmap_item metadata and
+// can be negative; it is 0 on older kernels.
+type KSMStat struct {
+	Run              uint64  `json:"run"`
+	PagesToScan      uint64  `json:"pagesToScan"`
+	SleepMillisecs   uint64  `json:"sleepMillisecs"`
+	MergeAcrossNodes bool    `json:"mergeAcrossNodes"`
+	UseZeroPages     bool    `json:"useZeroPages"`
+	PagesShared      uint64  `json:"pagesShared"`
+	PagesSharing     uint64  `json:"pagesSharing"`
+	PagesUnshared    uint64  `json:"pagesUnshared"`
+	PagesVolatile    uint64  `json:"pagesVolatile"`
+	ZeroPages        uint64  `json:"zeroPages"`
+	FullScans        uint64  `json:"fullScans"`
+	StableNodeChains uint64  `json:"stableNodeChains"`
+	StableNodeDups   uint64  `json:"stableNodeDups"`
+	GeneralProfit    int64   `json:"generalProfit"`
+	SharingRatio     float64 `json:"sharingRatio"`
+	Saved            uint64  `json:"saved"`
+}
+
+// KSMProcessStat is the KSM activity of a process from
+// /proc/<pid>/ksm_stat (Linux 6.1+). Profit is in bytes and can be
+// negative.
+type KSMProcessStat struct {
+	Pid          int32  `json:"pid"`
+	RmapItems    uint64 `json:"rmapItems"`
+	ZeroPages    uint64 `json:"zeroPages"`
+	MergingPages uint64 `json:"mergingPages"`
+	Profit       int64  `json:"profit"`
+	MergeAny     bool   `json:"mergeAny"`
+	Mergeable    bool   `json:"mergeable"`
+	Saved        uint64 `json:"saved"`
+}
+
+func (k KSMStat) String() string {
+	s, _ := json.Marshal(k)
+	return string(s)
+}
+
+func (k KSMProcessStat) String() string {
+	s, _ := json.Marshal(k)
+	return string(s)
+}
+
+// KSM returns the Kernel Samepage Merging state and the memory it saves.
+func KSM() (*KSMStat, error) {
+	return KSMWithContext(context.Background())
+}
+
+func KSMWithContext(ctx context.Context) (*KSMStat, error) {
+	dir := common.HostSysWithContext(ctx, "kernel/mm/ksm")
+	run, err := readSysUint(filepath.Join(dir, "run"))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &KSMStat{Run: run}
+	ret.PagesToScan, _ = readSysUint(filepath.Join(dir, "pages_to_scan"))
+	ret.SleepMillisecs, _ = readSysUint(filepath.Join(dir, "sleep_millisecs"))
+	if v, err := readSysUint(filepath.Join(dir, "merge_across_nodes")); err == nil {
+		ret.MergeAcrossNodes = v == 1
+	}
+	if v, err := readSysUint(filepath.Join(dir, "use_zero_pages")); err == nil {
+		ret.UseZeroPages = v == 1
+	}
+	ret.PagesShared, _ = readSysUint(filepath.Join(dir, "pages_shared"))
+	ret.PagesSharing, _ = readSysUint(filepath.Join(dir, "pages_sharing"))
+	ret.PagesUnshared, _ = readSysUint(filepath.Join(dir, "pages_unshared"))
+	ret.PagesVolatile, _ = readSysUint(filepath.Join(dir, "pages_volatile"))
+	ret.FullScans, _ = readSysUint(filepath.Join(dir, "full_scans"))
+	ret.StableNodeChains, _ = readSysUint(filepath.Join(dir, "stable_node_chains"))
+	ret.StableNodeDups, _ = readSysUint(filepath.Join(dir, "stable_node_dups"))
+	// renamed from zero_pages_sharing in Linux 6.6
+	if v, err := readSysUint(filepath.Join(dir, "ksm_zero_pages")); err == nil {
+		ret.ZeroPages = v
+	} else {
+		ret.ZeroPages, _ = readSysUint(filepath.Join(dir, "zero_pages_sharing"))
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	ret.Saved = (ret.PagesSharing + ret.ZeroPages) * pageSize
+	if ret.PagesShared > 0 {
+		ret.SharingRatio = float64(ret.PagesSharing) / float64(ret.PagesShared)
+	}
+	if lines, err := common.ReadLines(filepath.Join(dir, "general_profit")); err == nil && len(lines) > 0 {
+		ret.GeneralProfit, _ = strconv.ParseInt(strings.TrimSpace(lines[0]), 10, 64)
+	}
+	return ret, nil
+}
+
+// KSMProcess returns the KSM activity of a process.
+func KSMProcess(pid int32) (*KSMProcessStat, error) {
+	return KSMProcessWithContext(context.Background(), pid)
+}
+
+func KSMProcessWithContext(ctx context.Context, pid int32) (*KSMProcessStat, error) {
+	lines, err := common.ReadLines(common.HostProcWithContext(ctx, strconv.Itoa(int(pid)), "ksm_stat"))
+	if err != nil {
+		return nil, err
+	}
+	return parseKSMStat(pid, lines, uint64(os.Getpagesize())), nil
+}
+
+// KSMProcesses returns the KSM activity of every process with merged pages,
+// sorted by decreasing Saved.
+func KSMProcesses() ([]KSMProcessStat, error) {
+	return KSMProcessesWithContext(context.Background())
+}
+
+func KSMProcessesWithContext(ctx context.Context) ([]KSMProcessStat, error) {
+	entries, err := os.ReadDir(common.HostProcWithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	var ret []KSMProcessStat
+	for _, entry := range entries {
+		pid, err := strconv.ParseInt(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+		// the process may have exited, or ksm_stat be unsupported
+		lines, err := common.ReadLines(common.HostProcWithContext(ctx, entry.Name(), "ksm_stat"))
+		if err != nil {
+			continue
+		}
+		k := parseKSMStat(int32(pid), lines, pageSize)
+		if k.MergingPages == 0 && k.ZeroPages == 0 {
+			continue
+		}
+		ret = append(ret, *k)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Saved > ret[j].Saved })
+	return ret, nil
+}
+
+func parseKSMStat(pid int32, lines []string, pageSize uint64) *KSMProcessStat {
+	ret := &KSMProcessStat{Pid: pid}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "ksm_rmap_items":
+			ret.RmapItems, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "ksm_zero_pages":
+			ret.ZeroPages, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "ksm_merging_pages":
+			ret.MergingPages, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "ksm_process_profit":
+			ret.Profit, _ = strconv.ParseInt(fields[1], 10, 64)
+		case "ksm_merge_any:":
+			ret.MergeAny = fields[1] == "yes"
+		case "ksm_mergeable:":
+			ret.Mergeable = fields[1] == "yes"
+		}
+	}
+	ret.Saved = (ret.MergingPages + ret.ZeroPages) * pageSize
+	return ret
+}
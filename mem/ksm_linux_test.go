@@ -0,0 +1,82 @@
+//go:build linux
+
+package mem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func writeKSM(t *testing.T, files map[string]string) {
+	t.Helper()
+	sys := t.TempDir()
+	for name, value := range files {
+		writeTestFile(t, filepath.Join(sys, "kernel", "mm", "ksm", name), value+"\n")
+	}
+	t.Setenv("HOST_SYS", sys)
+}
+
+func TestKSM(t *testing.T) {
+	pageSize := uint64(os.Getpagesize())
+
+	// Linux 5.15, no general_profit nor zero page counter
+	writeKSM(t, map[string]string{
+		"run":                "1",
+		"merge_across_nodes": "1",
+		"pages_shared":       "100",
+		"pages_sharing":      "400",
+		"pages_unshared":     "50",
+	})
+	k, err := KSM()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), k.Run)
+	assert.Equal(t, true, k.MergeAcrossNodes)
+	assert.Equal(t, false, k.UseZeroPages)
+	assert.Equal(t, 4.0, k.SharingRatio)
+	assert.Equal(t, 400*pageSize, k.Saved)
+	assert.Equal(t, int64(0), k.GeneralProfit)
+
+	// Linux 6.6, zero pages are merged too
+	writeKSM(t, map[string]string{
+		"run":            "1",
+		"use_zero_pages": "1",
+		"pages_shared":   "100",
+		"pages_sharing":  "400",
+		"ksm_zero_pages": "20",
+		"general_profit": "-8192",
+	})
+	k, err = KSM()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(20), k.ZeroPages)
+	assert.Equal(t, 420*pageSize, k.Saved)
+	assert.Equal(t, int64(-8192), k.GeneralProfit)
+
+	writeKSM(t, map[string]string{})
+	_, err = KSM()
+	assert.NotNil(t, err)
+}
+
+func TestParseKSMStat(t *testing.T) {
+	const ksmStat = `ksm_rmap_items 300
+ksm_zero_pages 2
+ksm_merging_pages 10
+ksm_process_profit -4096
+ksm_merge_any: yes
+ksm_mergeable: no
+`
+	k := parseKSMStat(42, strings.Split(ksmStat, "\n"), 4096)
+	assert.DeepEqual(t, &KSMProcessStat{
+		Pid:          42,
+		RmapItems:    300,
+		ZeroPages:    2,
+		MergingPages: 10,
+		Profit:       -4096,
+		MergeAny:     true,
+		Mergeable:    false,
+		Saved:        12 * 4096,
+	}, k)
+}
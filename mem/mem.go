@@ -17,7 +17,9 @@ type VirtualMemoryStat struct {
 
 	// RAM used by programs
 	//
-	// This value is computed from the kernel specific values.
+	// This value is computed from the kernel specific values. On Linux it is
+	// the working set, the same value as WorkingSet and as the Used of a
+	// container in the docker package.
 	Used uint64 `json:"used"`
 
 	// Percentage of RAM used by programs
@@ -74,6 +76,27 @@ type VirtualMemoryStat struct {
 	HugePagesSurp  uint64 `json:"hugePagesSurp,omitempty"`
 	HugePageSize   uint64 `json:"hugePageSize,omitempty"`
 	AnonHugePages  uint64 `json:"anonHugePages,omitempty"`
+
+	// Linux specific numbers of newer kernels, zero on kernels without them
+	KReclaimable      uint64 `json:"kReclaimable,omitempty"`      // 4.20+
+	Percpu            uint64 `json:"percpu,omitempty"`            // 4.20+
+	ShmemHugePages    uint64 `json:"shmemHugePages,omitempty"`    // 4.8+
+	FileHugePages     uint64 `json:"fileHugePages,omitempty"`     // 5.4+
+	Zswap             uint64 `json:"zswap,omitempty"`             // 5.19+
+	Zswapped          uint64 `json:"zswapped,omitempty"`          // 5.19+
+	SecPageTables     uint64 `json:"secPageTables,omitempty"`     // 6.2+
+	Unaccepted        uint64 `json:"unaccepted,omitempty"`        // 6.5+
+	CmaTotal          uint64 `json:"cmaTotal,omitempty"`          // CONFIG_CMA
+	CmaFree           uint64 `json:"cmaFree,omitempty"`           // CONFIG_CMA
+	HardwareCorrupted uint64 `json:"hardwareCorrupted,omitempty"` // CONFIG_MEMORY_FAILURE
+
+	// Memory in use minus inactive file pages, computed the same way as
+	// the WorkingSet of a container, see WorkingSetStat.
+	WorkingSet uint64 `json:"workingSet,omitempty"`
+
+	// Raw holds every key of /proc/meminfo as found in the file, in bytes
+	// except for the HugePages_* page counts. It is only set on Linux.
+	Raw map[string]uint64 `json:"raw,omitempty"`
 }
 
 type SwapMemoryStat struct {
@@ -106,6 +129,20 @@ type SwapDevice struct {
 	Name      string `json:"name"`
 	UsedBytes uint64 `json:"usedBytes"`
 	FreeBytes uint64 `json:"freeBytes"`
+
+	// Linux specific numbers
+	// Type is one of partition, file or zram. BlockDevice is the block
+	// device holding the swap area, or the file system of a swap file, and
+	// the IO counters are its reads and writes from /proc/diskstats. They
+	// are left empty for swap files, whose device also serves every other
+	// file of the file system.
+	Type         string `json:"type,omitempty"`
+	Priority     int    `json:"priority,omitempty"`
+	BlockDevice  string `json:"blockDevice,omitempty"`
+	ReadIOs      uint64 `json:"readIos,omitempty"`
+	WriteIOs     uint64 `json:"writeIos,omitempty"`
+	ReadBytes    uint64 `json:"readBytes,omitempty"`
+	WrittenBytes uint64 `json:"writtenBytes,omitempty"`
 }
 
 func (m SwapDevice) String() string {
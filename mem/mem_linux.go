@@ -9,6 +9,7 @@ import (
 	"io"
 	"math"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -39,7 +40,7 @@ func fillFromMeminfoWithContext(ctx context.Context) (*VirtualMemoryStat, *ExVir
 	inactiveFile := false // "Inactive(file)" not available: 2.6.28 / Dec 2008
 	sReclaimable := false // "Sreclaimable:" not available: 2.6.19 / Nov 2006
 
-	ret := &VirtualMemoryStat{}
+	ret := &VirtualMemoryStat{Raw: make(map[string]uint64, len(lines))}
 	retEx := &ExVirtualMemory{}
 
 	for _, line := range lines {
@@ -51,6 +52,14 @@ func fillFromMeminfoWithContext(ctx context.Context) (*VirtualMemoryStat, *ExVir
 		value := strings.TrimSpace(fields[1])
 		value = strings.ReplaceAll(value, " kB", "")
 
+		if t, err := strconv.ParseUint(value, 10, 64); err == nil {
+			if strings.HasPrefix(key, "HugePages_") {
+				ret.Raw[key] = t
+			} else {
+				ret.Raw[key] = t * 1024
+			}
+		}
+
 		switch key {
 		case "MemTotal":
 			t, err := strconv.ParseUint(value, 10, 64)
@@ -293,6 +302,18 @@ func fillFromMeminfoWithContext(ctx context.Context) (*VirtualMemoryStat, *ExVir
 		}
 	}
 
+	ret.KReclaimable = ret.Raw["KReclaimable"]
+	ret.Percpu = ret.Raw["Percpu"]
+	ret.ShmemHugePages = ret.Raw["ShmemHugePages"]
+	ret.FileHugePages = ret.Raw["FileHugePages"]
+	ret.Zswap = ret.Raw["Zswap"]
+	ret.Zswapped = ret.Raw["Zswapped"]
+	ret.SecPageTables = ret.Raw["SecPageTables"]
+	ret.Unaccepted = ret.Raw["Unaccepted"]
+	ret.CmaTotal = ret.Raw["CmaTotal"]
+	ret.CmaFree = ret.Raw["CmaFree"]
+	ret.HardwareCorrupted = ret.Raw["HardwareCorrupted"]
+
 	ret.Cached += ret.Sreclaimable
 
 	if !memavail {
@@ -303,7 +324,9 @@ func fillFromMeminfoWithContext(ctx context.Context) (*VirtualMemoryStat, *ExVir
 		}
 	}
 
-	ret.Used = ret.Total - ret.Free - ret.Buffers - ret.Cached
+	// used is the working set, computed as for a container
+	ret.WorkingSet = workingSetFromMeminfo(ret, retEx).WorkingSet
+	ret.Used = ret.WorkingSet
 	ret.UsedPercent = float64(ret.Used) / float64(ret.Total) * 100.0
 
 	return ret, retEx, nil
@@ -330,52 +353,18 @@ func SwapMemoryWithContext(ctx context.Context) (*SwapMemoryStat, error) {
 	} else {
 		ret.UsedPercent = 0
 	}
-	filename := common.HostProcWithContext(ctx, "vmstat")
-	lines, _ := common.ReadLines(filename)
-	for _, l := range lines {
-		fields := strings.Fields(l)
-		if len(fields) < 2 {
-			continue
-		}
-		switch fields[0] {
-		case "pswpin":
-			value, err := strconv.ParseUint(fields[1], 10, 64)
-			if err != nil {
-				continue
-			}
-			ret.Sin = value * 4 * 1024
-		case "pswpout":
-			value, err := strconv.ParseUint(fields[1], 10, 64)
-			if err != nil {
-				continue
-			}
-			ret.Sout = value * 4 * 1024
-		case "pgpgin":
-			value, err := strconv.ParseUint(fields[1], 10, 64)
-			if err != nil {
-				continue
-			}
-			ret.PgIn = value * 4 * 1024
-		case "pgpgout":
-			value, err := strconv.ParseUint(fields[1], 10, 64)
-			if err != nil {
-				continue
-			}
-			ret.PgOut = value * 4 * 1024
-		case "pgfault":
-			value, err := strconv.ParseUint(fields[1], 10, 64)
-			if err != nil {
-				continue
-			}
-			ret.PgFault = value * 4 * 1024
-		case "pgmajfault":
-			value, err := strconv.ParseUint(fields[1], 10, 64)
-			if err != nil {
-				continue
-			}
-			ret.PgMajFault = value * 4 * 1024
-		}
+	vmstat, err := VMStatWithContext(ctx)
+	if err != nil {
+		// the sysinfo values are still valid without /proc/vmstat
+		return ret, nil
 	}
+	ret.Sin = vmstat.Bytes(vmstat.PswpIn)
+	ret.Sout = vmstat.Bytes(vmstat.PswpOut)
+	// pgpgin and pgpgout are counted in KiB, not in pages
+	ret.PgIn = vmstat.PgpgIn * 1024
+	ret.PgOut = vmstat.PgpgOut * 1024
+	ret.PgFault = vmstat.Bytes(vmstat.PgFault)
+	ret.PgMajFault = vmstat.Bytes(vmstat.PgMajFault)
 	return ret, nil
 }
 
@@ -421,15 +410,29 @@ func calculateAvailVmem(ctx context.Context, ret *VirtualMemoryStat, retEx *ExVi
 	return availMemory
 }
 
+// readSysUint reads the leading number of a sysfs attribute, ignoring
+// anything that follows, e.g. the per node breakdown of "16884 N0=16884".
+func readSysUint(filename string) (uint64, error) {
+	lines, err := common.ReadLines(filename)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(strings.Join(lines, " "))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("%s is empty", filename)
+	}
+	return strconv.ParseUint(fields[0], 10, 64)
+}
+
 const swapsFilename = "swaps"
 
 // swaps file column indexes
 const (
-	nameCol = 0
-	// typeCol     = 1
-	totalCol = 2
-	usedCol  = 3
-	// priorityCol = 4
+	nameCol     = 0
+	typeCol     = 1
+	totalCol    = 2
+	usedCol     = 3
+	priorityCol = 4
 )
 
 func SwapDevices() ([]*SwapDevice, error) {
@@ -444,7 +447,12 @@ func SwapDevicesWithContext(ctx context.Context) ([]*SwapDevice, error) {
 	}
 	defer f.Close()
 
-	return parseSwapsFile(ctx, f)
+	swapDevices, err := parseSwapsFile(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	fillSwapDevicesIO(ctx, swapDevices)
+	return swapDevices, nil
 }
 
 func parseSwapsFile(ctx context.Context, r io.Reader) ([]*SwapDevice, error) {
@@ -460,7 +468,7 @@ func parseSwapsFile(ctx context.Context, r io.Reader) ([]*SwapDevice, error) {
 
 	// Check header headerFields are as expected
 	headerFields := strings.Fields(scanner.Text())
-	if len(headerFields) < usedCol {
+	if len(headerFields) <= usedCol {
 		return nil, fmt.Errorf("couldn't parse %q: too few fields in header", swapsFilePath)
 	}
 	if headerFields[nameCol] != "Filename" {
@@ -476,7 +484,7 @@ func parseSwapsFile(ctx context.Context, r io.Reader) ([]*SwapDevice, error) {
 	var swapDevices []*SwapDevice
 	for scanner.Scan() {
 		fields := strings.Fields(scanner.Text())
-		if len(fields) < usedCol {
+		if len(fields) <= usedCol {
 			return nil, fmt.Errorf("couldn't parse %q: too few fields", swapsFilePath)
 		}
 
@@ -490,11 +498,23 @@ func parseSwapsFile(ctx context.Context, r io.Reader) ([]*SwapDevice, error) {
 			return nil, fmt.Errorf("couldn't parse 'Used' column in %q: %w", swapsFilePath, err)
 		}
 
-		swapDevices = append(swapDevices, &SwapDevice{
-			Name:      fields[nameCol],
+		swapDevice := &SwapDevice{
+			Name:      unescapeSwapName(fields[nameCol]),
 			UsedBytes: usedKiB * 1024,
 			FreeBytes: (totalKiB - usedKiB) * 1024,
-		})
+			Type:      fields[typeCol],
+		}
+		if strings.HasPrefix(filepath.Base(swapDevice.Name), "zram") {
+			swapDevice.Type = "zram"
+		}
+		if len(fields) > priorityCol {
+			priority, err := strconv.Atoi(fields[priorityCol])
+			if err != nil {
+				return nil, fmt.Errorf("couldn't parse 'Priority' column in %q: %w", swapsFilePath, err)
+			}
+			swapDevice.Priority = priority
+		}
+		swapDevices = append(swapDevices, swapDevice)
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -0,0 +1,59 @@
+//go:build linux
+
+package mem
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+	"github.com/ravoni4devs/syspector/internal/test/fs"
+)
+
+func TestMeminfoNewerKernel(t *testing.T) {
+	proc := t.TempDir()
+	writeTestFile(t, filepath.Join(proc, "meminfo"), string(fs.ReadFromTestData("meminfo_6.8")))
+	t.Setenv("HOST_PROC", proc)
+
+	vm, err := GetStat()
+	assert.Nil(t, err)
+
+	const kB = 1024
+	assert.Equal(t, uint64(541216*kB), vm.KReclaimable)
+	assert.Equal(t, uint64(9216*kB), vm.Percpu)
+	assert.Equal(t, uint64(12288*kB), vm.ShmemHugePages)
+	assert.Equal(t, uint64(4096*kB), vm.FileHugePages)
+	assert.Equal(t, uint64(61440*kB), vm.Zswap)
+	assert.Equal(t, uint64(245760*kB), vm.Zswapped)
+	assert.Equal(t, uint64(2048*kB), vm.SecPageTables)
+	assert.Equal(t, uint64(131072*kB), vm.Unaccepted)
+	assert.Equal(t, uint64(65536*kB), vm.CmaTotal)
+	assert.Equal(t, uint64(60000*kB), vm.CmaFree)
+	assert.Equal(t, uint64(4*kB), vm.HardwareCorrupted)
+
+	// every key is kept, in bytes except for the HugePages_* counts
+	assert.Equal(t, 57, len(vm.Raw))
+	assert.Equal(t, uint64(16303404*kB), vm.Raw["MemTotal"])
+	assert.Equal(t, uint64(5639948*kB), vm.Raw["Inactive(file)"])
+	assert.Equal(t, uint64(34359738367*kB), vm.Raw["VmallocTotal"])
+	assert.Equal(t, uint64(2097152*kB), vm.Raw["DirectMap1G"])
+	assert.Equal(t, uint64(16), vm.Raw["HugePages_Total"])
+	assert.Equal(t, uint64(2), vm.Raw["HugePages_Rsvd"])
+	assert.Equal(t, uint64(2048*kB), vm.Raw["Hugepagesize"])
+	assert.Equal(t, uint64(16), vm.HugePagesTotal)
+}
+
+func TestMeminfoOlderKernel(t *testing.T) {
+	proc := t.TempDir()
+	writeTestFile(t, filepath.Join(proc, "meminfo"), string(fs.ReadFromTestData("meminfo")))
+	t.Setenv("HOST_PROC", proc)
+
+	vm, err := GetStat()
+	assert.Nil(t, err)
+	// keys the kernel does not report stay at zero and out of Raw
+	assert.Equal(t, uint64(0), vm.Zswap)
+	assert.Equal(t, uint64(0), vm.Unaccepted)
+	_, ok := vm.Raw["Zswap"]
+	assert.Equal(t, false, ok)
+	assert.Equal(t, uint64(16064*1024), vm.Percpu)
+}
@@ -0,0 +1,182 @@
+//go:build linux
+
+package mem
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ravoni4devs/syspector/cpu"
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// NUMANodeStat holds the memory statistics of a NUMA node from
+// /sys/devices/system/node/nodeN. Memory values are in bytes, the numastat
+// counters in pages.
+//
+// Distance is the row of the node distance matrix for this node, keyed by
+// node number: Distance[j] is the relative cost of accessing node j. Only
+// online nodes have a distance.
+type NUMANodeStat struct {
+	Node        int        `json:"node"`
+	CPUs        cpu.CPUSet `json:"cpus"`
+	Total       uint64     `json:"total"`
+	Free        uint64     `json:"free"`
+	Used        uint64     `json:"used"`
+	UsedPercent float64    `json:"usedPercent"`
+	FilePages   uint64     `json:"filePages"`
+	AnonPages   uint64     `json:"anonPages"`
+	Shmem       uint64     `json:"shmem"`
+	Slab        uint64     `json:"slab"`
+
+	NumaHit       uint64 `json:"numaHit"`
+	NumaMiss      uint64 `json:"numaMiss"`
+	NumaForeign   uint64 `json:"numaForeign"`
+	InterleaveHit uint64 `json:"interleaveHit"`
+	LocalNode     uint64 `json:"localNode"`
+	OtherNode     uint64 `json:"otherNode"`
+
+	Distance map[int]int `json:"distance"`
+
+	// Every key of the node meminfo (in bytes, HugePages_* in pages) and
+	// vmstat files.
+	Meminfo map[string]uint64 `json:"meminfo"`
+	VMStat  map[string]uint64 `json:"vmstat"`
+}
+
+func (n NUMANodeStat) String() string {
+	s, _ := json.Marshal(n)
+	return string(s)
+}
+
+// NUMANodes returns the statistics of every NUMA node, sorted by node
+// number. Systems without NUMA support report a single node 0, or nothing
+// when the kernel is built without CONFIG_NUMA.
+func NUMANodes() ([]NUMANodeStat, error) {
+	return NUMANodesWithContext(context.Background())
+}
+
+func NUMANodesWithContext(ctx context.Context) ([]NUMANodeStat, error) {
+	dirs, err := filepath.Glob(common.HostSysWithContext(ctx, "devices/system/node/node[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+
+	// nodeN/distance has a column per online node, which may be sparse
+	var online cpu.CPUSet
+	if lines, err := common.ReadLines(common.HostSysWithContext(ctx, "devices/system/node/online")); err == nil && len(lines) > 0 {
+		online, _ = cpu.ParseCPUSet(lines[0])
+	}
+
+	var ret []NUMANodeStat
+	for _, dir := range dirs {
+		n, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		node, err := readNUMANode(dir, n, online)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, node)
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Node < ret[j].Node })
+	return ret, nil
+}
+
+// readNUMANode reads the node n from dir. online are the online nodes, the
+// columns of its distance file.
+func readNUMANode(dir string, n int, online cpu.CPUSet) (NUMANodeStat, error) {
+	ret := NUMANodeStat{
+		Node:    n,
+		Meminfo: make(map[string]uint64),
+		VMStat:  make(map[string]uint64),
+	}
+
+	lines, err := common.ReadLines(filepath.Join(dir, "meminfo"))
+	if err != nil {
+		return ret, err
+	}
+	for _, line := range lines {
+		// Node 0 MemTotal:       16318424 kB
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		key := strings.TrimSuffix(fields[2], ":")
+		value, err := strconv.ParseUint(fields[3], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 4 && fields[4] == "kB" {
+			value *= 1024
+		}
+		ret.Meminfo[key] = value
+	}
+	ret.Total = ret.Meminfo["MemTotal"]
+	ret.Free = ret.Meminfo["MemFree"]
+	ret.Used = ret.Meminfo["MemUsed"]
+	ret.FilePages = ret.Meminfo["FilePages"]
+	ret.AnonPages = ret.Meminfo["AnonPages"]
+	ret.Shmem = ret.Meminfo["Shmem"]
+	ret.Slab = ret.Meminfo["Slab"]
+	if ret.Total > 0 {
+		ret.UsedPercent = float64(ret.Used) / float64(ret.Total) * 100.0
+	}
+
+	numastat := readKeyValueFile(filepath.Join(dir, "numastat"))
+	ret.NumaHit = numastat["numa_hit"]
+	ret.NumaMiss = numastat["numa_miss"]
+	ret.NumaForeign = numastat["numa_foreign"]
+	ret.InterleaveHit = numastat["interleave_hit"]
+	ret.LocalNode = numastat["local_node"]
+	ret.OtherNode = numastat["other_node"]
+
+	ret.VMStat = readKeyValueFile(filepath.Join(dir, "vmstat"))
+
+	if lines, err := common.ReadLines(filepath.Join(dir, "cpulist")); err == nil && len(lines) > 0 {
+		ret.CPUs, _ = cpu.ParseCPUSet(lines[0])
+	}
+
+	if lines, err := common.ReadLines(filepath.Join(dir, "distance")); err == nil && len(lines) > 0 {
+		fields := strings.Fields(lines[0])
+		if len(fields) == len(online) {
+			ret.Distance = make(map[int]int, len(fields))
+			for i, field := range fields {
+				d, err := strconv.Atoi(field)
+				if err != nil {
+					continue
+				}
+				ret.Distance[online[i]] = d
+			}
+		}
+	}
+	return ret, nil
+}
+
+// readKeyValueFile parses files made of "key value" lines, such as vmstat.
+// Unreadable files yield an empty map.
+func readKeyValueFile(filename string) map[string]uint64 {
+	ret := make(map[string]uint64)
+	lines, err := common.ReadLines(filename)
+	if err != nil {
+		return ret
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		ret[fields[0]] = value
+	}
+	return ret
+}
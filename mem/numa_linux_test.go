@@ -0,0 +1,70 @@
+//go:build linux
+
+package mem
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ravoni4devs/syspector/cpu"
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func TestNUMANodesSparse(t *testing.T) {
+	sys := t.TempDir()
+	t.Setenv("HOST_SYS", sys)
+	nodes := filepath.Join(sys, "devices", "system", "node")
+	// node1 is offline, node3 is a CPU-less CXL memory node
+	writeTestFile(t, filepath.Join(nodes, "online"), "0,2-3\n")
+	writeTestFile(t, filepath.Join(nodes, "node0", "meminfo"),
+		"Node 0 MemTotal:       16777216 kB\nNode 0 MemFree:         4194304 kB\nNode 0 MemUsed:        12582912 kB\n"+
+			"Node 0 FilePages:       2097152 kB\nNode 0 HugePages_Total:     2\n")
+	writeTestFile(t, filepath.Join(nodes, "node0", "numastat"), "numa_hit 1000\nnuma_miss 10\nlocal_node 990\nother_node 10\n")
+	writeTestFile(t, filepath.Join(nodes, "node0", "vmstat"), "nr_free_pages 1048576\n")
+	writeTestFile(t, filepath.Join(nodes, "node0", "cpulist"), "0-3\n")
+	writeTestFile(t, filepath.Join(nodes, "node0", "distance"), "10 21 24\n")
+	writeTestFile(t, filepath.Join(nodes, "node2", "meminfo"), "Node 2 MemTotal:       16777216 kB\n")
+	writeTestFile(t, filepath.Join(nodes, "node2", "cpulist"), "4-7\n")
+	writeTestFile(t, filepath.Join(nodes, "node2", "distance"), "21 10 24\n")
+	writeTestFile(t, filepath.Join(nodes, "node3", "meminfo"), "Node 3 MemTotal:      67108864 kB\n")
+	writeTestFile(t, filepath.Join(nodes, "node3", "cpulist"), "\n")
+	writeTestFile(t, filepath.Join(nodes, "node3", "distance"), "24 24 10\n")
+
+	stats, err := NUMANodes()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(stats))
+
+	n := stats[0]
+	assert.Equal(t, 0, n.Node)
+	assert.DeepEqual(t, cpu.CPUSet{0, 1, 2, 3}, n.CPUs)
+	assert.Equal(t, uint64(16*1024*1024*1024), n.Total)
+	assert.Equal(t, uint64(12*1024*1024*1024), n.Used)
+	assert.Equal(t, 75.0, n.UsedPercent)
+	assert.Equal(t, uint64(2*1024*1024*1024), n.FilePages)
+	assert.Equal(t, uint64(2), n.Meminfo["HugePages_Total"])
+	assert.Equal(t, uint64(1000), n.NumaHit)
+	assert.Equal(t, uint64(990), n.LocalNode)
+	assert.Equal(t, uint64(1048576), n.VMStat["nr_free_pages"])
+
+	// the columns are the online nodes, not the node numbers
+	assert.DeepEqual(t, map[int]int{0: 10, 2: 21, 3: 24}, n.Distance)
+	assert.DeepEqual(t, map[int]int{0: 21, 2: 10, 3: 24}, stats[1].Distance)
+	assert.Equal(t, 2, stats[1].Node)
+	assert.DeepEqual(t, map[int]int{0: 24, 2: 24, 3: 10}, stats[2].Distance)
+	assert.Equal(t, 0, len(stats[2].CPUs))
+}
+
+func TestReadNUMANodeDistanceMismatch(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "meminfo"), "Node 0 MemTotal:       1024 kB\n")
+	writeTestFile(t, filepath.Join(dir, "distance"), "10 20\n")
+
+	// a node went online between the reads, the columns cannot be mapped
+	n, err := readNUMANode(dir, 0, cpu.CPUSet{0, 1, 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(n.Distance))
+
+	n, err = readNUMANode(dir, 0, cpu.CPUSet{0, 4})
+	assert.Nil(t, err)
+	assert.DeepEqual(t, map[int]int{0: 10, 4: 20}, n.Distance)
+}
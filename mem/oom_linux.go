@@ -0,0 +1,446 @@
+//go:build linux
+
+package mem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// OOMEventKind tells what an OOMEvent reports.
+type OOMEventKind string
+
+const (
+	// OOMKill is a process killed by the OOM killer.
+	OOMKill OOMEventKind = "oom_kill"
+	// OOMCgroup is a cgroup that reached its limit and invoked the OOM
+	// killer, memory.events "oom".
+	OOMCgroup OOMEventKind = "oom"
+	// OOMMemoryHigh is a cgroup throttled above memory.high.
+	OOMMemoryHigh OOMEventKind = "high"
+	// OOMMemoryMax is a cgroup that hit memory.max and had to reclaim.
+	OOMMemoryMax OOMEventKind = "max"
+)
+
+// OOMEvent sources
+const (
+	OOMSourceKmsg         = "kmsg"
+	OOMSourceVMStat       = "vmstat"
+	OOMSourceMemoryEvents = "memory.events"
+)
+
+// OOMEvent is an out of memory event. Victim details (Pid, Comm, RSS...)
+// are only known from /dev/kmsg, which usually requires root or
+// CAP_SYSLOG; events derived from counters only carry Cgroup, when known,
+// and Count, the number of occurrences since the previous poll.
+// RSS values are in bytes.
+type OOMEvent struct {
+	Time        time.Time    `json:"time"`
+	Kind        OOMEventKind `json:"kind"`
+	Source      string       `json:"source"`
+	Count       uint64       `json:"count"`
+	Pid         int32        `json:"pid,omitempty"`
+	Comm        string       `json:"comm,omitempty"`
+	UID         uint32       `json:"uid,omitempty"`
+	Cgroup      string       `json:"cgroup,omitempty"`
+	OOMCgroup   string       `json:"oomCgroup,omitempty"`
+	Constraint  string       `json:"constraint,omitempty"`
+	TotalVM     uint64       `json:"totalVm,omitempty"`
+	AnonRSS     uint64       `json:"anonRss,omitempty"`
+	FileRSS     uint64       `json:"fileRss,omitempty"`
+	ShmemRSS    uint64       `json:"shmemRss,omitempty"`
+	RSS         uint64       `json:"rss,omitempty"`
+	OOMScoreAdj int          `json:"oomScoreAdj,omitempty"`
+}
+
+func (e OOMEvent) String() string {
+	s, _ := json.Marshal(e)
+	return string(s)
+}
+
+// WatchOOM polls every interval for out of memory events and sends them on
+// the returned channel, which is closed once ctx is done. Only events
+// happening after the call are reported.
+//
+// Kills are read from /dev/kmsg when it is readable, otherwise from the
+// oom_kill counter of /proc/vmstat. memory.events of the given cgroup v2
+// directories, relative to /sys/fs/cgroup, are watched as well; with no
+// cgroups every cgroup of the hierarchy is watched, through
+// memory.events.local so that kills are not reported once per ancestor.
+// Nested cgroups given explicitly report their events once, in the deepest
+// watched cgroup.
+func WatchOOM(ctx context.Context, interval time.Duration, cgroups ...string) (<-chan OOMEvent, error) {
+	w := &oomWatcher{
+		cgroupRoot: common.HostSysWithContext(ctx, "fs/cgroup"),
+		cgroups:    cgroups,
+		pending:    make(map[int32]OOMEvent),
+	}
+	vmstat, err := VMStatWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w.oomKills = vmstat.Raw["oom_kill"]
+	w.events = w.readMemoryEvents()
+
+	// kmsg is optional, dmesg_restrict commonly forbids it. The raw file
+	// descriptor is used as os.File reads would block on EAGAIN.
+	w.kmsg = -1
+	if fd, err := unix.Open(common.HostDevWithContext(ctx, "kmsg"), unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0); err == nil {
+		if _, err := unix.Seek(fd, 0, io.SeekEnd); err == nil {
+			w.kmsg = fd
+		} else {
+			unix.Close(fd)
+		}
+	}
+
+	ch := make(chan OOMEvent)
+	go func() {
+		defer close(ch)
+		if w.kmsg >= 0 {
+			defer unix.Close(w.kmsg)
+		}
+		for {
+			if err := common.Sleep(ctx, interval); err != nil {
+				return
+			}
+			for _, e := range w.poll(ctx) {
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+type oomWatcher struct {
+	cgroupRoot string
+	cgroups    []string
+	kmsg       int
+	oomKills   uint64
+	events     map[string]map[string]uint64
+	// oom-kill records waiting for their "Killed process" line
+	pending map[int32]OOMEvent
+	// kills already reported that the counters have not caught up with yet,
+	// as they are read before /dev/kmsg
+	vmstatAhead uint64
+	cgroupAhead map[string]uint64
+}
+
+// poll reports the events since the previous poll. Counters are read before
+// /dev/kmsg, as the kernel bumps them before logging the kill, and every
+// kill is reported once: from kmsg when it is readable, otherwise from the
+// deepest watched cgroup holding the victim, otherwise from vmstat.
+func (w *oomWatcher) poll(ctx context.Context) []OOMEvent {
+	now := time.Now()
+	var ret []OOMEvent
+
+	var vmstatKills uint64
+	vmstat, vmstatErr := VMStatWithContext(ctx)
+	if vmstatErr == nil {
+		if count := vmstat.Raw["oom_kill"]; count > w.oomKills {
+			vmstatKills = count - w.oomKills
+		}
+		w.oomKills = vmstat.Raw["oom_kill"]
+	}
+	events := w.readMemoryEvents()
+	kills := w.readKmsg()
+	ret = append(ret, kills...)
+
+	killed := make(map[string]uint64)
+	for _, k := range kills {
+		if cgroup, ok := w.watchingCgroup(events, k.Cgroup); ok {
+			killed[cgroup]++
+		}
+	}
+
+	var cgroupKills uint64
+	ahead := make(map[string]uint64)
+	for cgroup := range events {
+		if _, ok := w.events[cgroup]; !ok {
+			continue // new cgroup, baseline only
+		}
+		for _, kind := range []OOMEventKind{OOMCgroup, OOMKill, OOMMemoryHigh, OOMMemoryMax} {
+			count := w.eventDelta(events, cgroup, string(kind))
+			if kind == OOMKill {
+				// already reported with victim details
+				reported := w.cgroupAhead[cgroup] + killed[cgroup]
+				if reported >= count {
+					ahead[cgroup] = reported - count
+					continue
+				}
+				count -= reported
+				cgroupKills += count
+			}
+			if count == 0 {
+				continue
+			}
+			ret = append(ret, OOMEvent{
+				Time:   now,
+				Kind:   kind,
+				Source: OOMSourceMemoryEvents,
+				Count:  count,
+				Cgroup: cgroup,
+			})
+		}
+	}
+	w.events = events
+	w.cgroupAhead = ahead
+
+	// kills of unwatched cgroups, or global ones, without kmsg
+	if vmstatErr == nil {
+		reported := w.vmstatAhead + uint64(len(kills)) + cgroupKills
+		if vmstatKills > reported {
+			ret = append(ret, OOMEvent{
+				Time:   now,
+				Kind:   OOMKill,
+				Source: OOMSourceVMStat,
+				Count:  vmstatKills - reported,
+			})
+			reported = vmstatKills
+		}
+		w.vmstatAhead = reported - vmstatKills
+	}
+	return ret
+}
+
+// eventDelta returns the growth of the key counter of cgroup since the
+// previous poll. memory.events is hierarchical, so when explicit cgroups
+// are watched the growth of the watched cgroups below cgroup is left to
+// them.
+func (w *oomWatcher) eventDelta(events map[string]map[string]uint64, cgroup, key string) uint64 {
+	delta := func(cgroup string) uint64 {
+		last, ok := w.events[cgroup]
+		if !ok {
+			return 0
+		}
+		if cur, prev := events[cgroup][key], last[key]; cur > prev {
+			return cur - prev
+		}
+		return 0
+	}
+
+	ret := delta(cgroup)
+	if len(w.cgroups) == 0 {
+		return ret // memory.events.local
+	}
+	for child := range events {
+		if child == cgroup || !isCgroupAncestor(cgroup, child) {
+			continue
+		}
+		if parent, _ := w.watchingCgroup(events, filepath.Dir(child)); parent != cgroup {
+			continue // accounted by a watched cgroup in between
+		}
+		if d := delta(child); d < ret {
+			ret -= d
+		} else {
+			ret = 0
+		}
+	}
+	return ret
+}
+
+// watchingCgroup returns the deepest watched cgroup holding cgroup.
+func (w *oomWatcher) watchingCgroup(events map[string]map[string]uint64, cgroup string) (string, bool) {
+	if cgroup == "" {
+		return "", false
+	}
+	for c := filepath.Clean("/" + cgroup); ; c = filepath.Dir(c) {
+		if _, ok := events[c]; ok {
+			return c, true
+		}
+		if c == "/" || len(w.cgroups) == 0 {
+			// memory.events.local only counts the cgroup itself
+			return "", false
+		}
+	}
+}
+
+func isCgroupAncestor(ancestor, cgroup string) bool {
+	return ancestor == "/" || cgroup == ancestor || strings.HasPrefix(cgroup, ancestor+"/")
+}
+
+// readMemoryEvents returns the memory.events counters of the watched
+// cgroups keyed by cgroup path, e.g. "/system.slice/foo.service".
+func (w *oomWatcher) readMemoryEvents() map[string]map[string]uint64 {
+	ret := make(map[string]map[string]uint64)
+	if len(w.cgroups) > 0 {
+		for _, cgroup := range w.cgroups {
+			filename := filepath.Join(w.cgroupRoot, cgroup, "memory.events")
+			if common.PathExists(filename) {
+				ret["/"+strings.Trim(cgroup, "/")] = readKeyValueFile(filename)
+			}
+		}
+		return ret
+	}
+
+	_ = filepath.WalkDir(w.cgroupRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		filename := filepath.Join(path, "memory.events.local")
+		if !common.PathExists(filename) {
+			return nil
+		}
+		rel, err := filepath.Rel(w.cgroupRoot, path)
+		if err != nil {
+			return nil
+		}
+		ret[filepath.Clean("/"+rel)] = readKeyValueFile(filename)
+		return nil
+	})
+	return ret
+}
+
+// readKmsg reads the records logged since the previous call and returns the
+// OOM kills found. Each read of /dev/kmsg returns a single record.
+func (w *oomWatcher) readKmsg() []OOMEvent {
+	if w.kmsg < 0 {
+		return nil
+	}
+	var ret []OOMEvent
+	buf := make([]byte, 8192)
+	for {
+		n, err := unix.Read(w.kmsg, buf)
+		if errors.Is(err, unix.EPIPE) {
+			continue // records were overwritten, carry on with the next one
+		}
+		if err != nil || n <= 0 {
+			break
+		}
+		if e, ok := w.parseKmsgRecord(string(buf[:n])); ok {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+// parseKmsgRecord parses a "prio,seq,usec,flags;message" record. The OOM
+// killer logs an "oom-kill:" line with the cgroups of the victim followed
+// by a "Killed process" line with its memory usage.
+func (w *oomWatcher) parseKmsgRecord(record string) (OOMEvent, bool) {
+	header, message, found := strings.Cut(strings.SplitN(record, "\n", 2)[0], ";")
+	if !found {
+		return OOMEvent{}, false
+	}
+
+	switch {
+	case strings.HasPrefix(message, "oom-kill:"):
+		e := parseOOMKillLine(strings.TrimPrefix(message, "oom-kill:"))
+		if e.Pid != 0 {
+			w.pending[e.Pid] = e
+		}
+		return OOMEvent{}, false
+	case strings.Contains(message, "Killed process "):
+		e, ok := parseKilledProcessLine(message)
+		if !ok {
+			return OOMEvent{}, false
+		}
+		if p, ok := w.pending[e.Pid]; ok {
+			e.Cgroup, e.OOMCgroup, e.Constraint, e.UID = p.Cgroup, p.OOMCgroup, p.Constraint, p.UID
+			delete(w.pending, e.Pid)
+		}
+		e.Time = kmsgTime(header)
+		return e, true
+	}
+	return OOMEvent{}, false
+}
+
+// parseOOMKillLine parses the comma separated key=value list of
+// "oom-kill:constraint=CONSTRAINT_MEMCG,...,task_memcg=/foo,task=bar,pid=1,uid=0".
+func parseOOMKillLine(s string) OOMEvent {
+	var e OOMEvent
+	for _, field := range strings.Split(s, ",") {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "constraint":
+			e.Constraint = value
+		case "oom_memcg":
+			e.OOMCgroup = value
+		case "task_memcg":
+			e.Cgroup = value
+		case "task":
+			e.Comm = value
+		case "pid":
+			pid, _ := strconv.ParseInt(value, 10, 32)
+			e.Pid = int32(pid)
+		case "uid":
+			uid, _ := strconv.ParseUint(value, 10, 32)
+			e.UID = uint32(uid)
+		}
+	}
+	return e
+}
+
+// parseKilledProcessLine parses "Out of memory: Killed process 1234 (comm)
+// total-vm:1024kB, anon-rss:512kB, file-rss:0kB, shmem-rss:0kB, UID:0
+// pgtables:64kB oom_score_adj:0".
+func parseKilledProcessLine(message string) (OOMEvent, bool) {
+	_, rest, _ := strings.Cut(message, "Killed process ")
+	pidField, rest, _ := strings.Cut(rest, " ")
+	pid, err := strconv.ParseInt(pidField, 10, 32)
+	if err != nil {
+		return OOMEvent{}, false
+	}
+	e := OOMEvent{Kind: OOMKill, Source: OOMSourceKmsg, Count: 1, Pid: int32(pid)}
+	if start, end := strings.Index(rest, "("), strings.LastIndex(rest, ")"); start >= 0 && end > start {
+		e.Comm = rest[start+1 : end]
+		rest = rest[end+1:]
+	}
+
+	for _, field := range strings.Fields(strings.ReplaceAll(rest, ",", " ")) {
+		key, value, found := strings.Cut(field, ":")
+		if !found {
+			continue
+		}
+		kb, _ := strconv.ParseUint(strings.TrimSuffix(value, "kB"), 10, 64)
+		switch key {
+		case "total-vm":
+			e.TotalVM = kb * 1024
+		case "anon-rss":
+			e.AnonRSS = kb * 1024
+		case "file-rss":
+			e.FileRSS = kb * 1024
+		case "shmem-rss":
+			e.ShmemRSS = kb * 1024
+		case "UID":
+			e.UID = uint32(kb)
+		case "oom_score_adj":
+			e.OOMScoreAdj, _ = strconv.Atoi(value)
+		}
+	}
+	e.RSS = e.AnonRSS + e.FileRSS + e.ShmemRSS
+	return e, true
+}
+
+// kmsgTime converts the monotonic microseconds of a kmsg record header to
+// wall clock time, falling back to now.
+func kmsgTime(header string) time.Time {
+	now := time.Now()
+	fields := strings.Split(header, ",")
+	if len(fields) < 3 {
+		return now
+	}
+	usec, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return now
+	}
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return now
+	}
+	return now.Add(-time.Duration(ts.Nano()-usec*1000) * time.Nanosecond)
+}
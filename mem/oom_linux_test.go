@@ -0,0 +1,156 @@
+//go:build linux
+
+package mem
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+type oomTestTree struct {
+	t      *testing.T
+	proc   string
+	cgroup string
+}
+
+func newOOMTestTree(t *testing.T) *oomTestTree {
+	root := t.TempDir()
+	tree := &oomTestTree{t: t, proc: filepath.Join(root, "proc"), cgroup: filepath.Join(root, "sys", "fs", "cgroup")}
+	t.Setenv("HOST_PROC", tree.proc)
+	t.Setenv("HOST_SYS", filepath.Join(root, "sys"))
+	tree.vmstat(0)
+	return tree
+}
+
+func (tree *oomTestTree) vmstat(oomKills int) {
+	writeTestFile(tree.t, filepath.Join(tree.proc, "vmstat"), fmt.Sprintf("pswpin 0\noom_kill %d\n", oomKills))
+}
+
+func (tree *oomTestTree) events(cgroup, file string, oom, oomKills int) {
+	writeTestFile(tree.t, filepath.Join(tree.cgroup, cgroup, file),
+		fmt.Sprintf("low 0\nhigh 0\nmax 0\noom %d\noom_kill %d\noom_group_kill 0\n", oom, oomKills))
+}
+
+func (tree *oomTestTree) watcher(cgroups ...string) *oomWatcher {
+	w := &oomWatcher{
+		cgroupRoot: tree.cgroup,
+		cgroups:    cgroups,
+		kmsg:       -1,
+		pending:    make(map[int32]OOMEvent),
+	}
+	w.events = w.readMemoryEvents()
+	return w
+}
+
+func oomKillCounts(events []OOMEvent) map[string]uint64 {
+	ret := make(map[string]uint64)
+	for _, e := range events {
+		if e.Kind == OOMKill {
+			ret[e.Source+":"+e.Cgroup] += e.Count
+		}
+	}
+	return ret
+}
+
+func TestWatchOOMLocalEvents(t *testing.T) {
+	tree := newOOMTestTree(t)
+	tree.events("a", "memory.events.local", 0, 0)
+	tree.events("a/b", "memory.events.local", 0, 0)
+	w := tree.watcher()
+	ctx := context.Background()
+
+	// a kill in /a/b, without kmsg, is reported once
+	tree.events("a/b", "memory.events.local", 1, 1)
+	tree.vmstat(1)
+	assert.DeepEqual(t, map[string]uint64{"memory.events:/a/b": 1}, oomKillCounts(w.poll(ctx)))
+
+	// two kills outside of any cgroup with memory.events.local
+	tree.vmstat(3)
+	assert.DeepEqual(t, map[string]uint64{"vmstat:": 2}, oomKillCounts(w.poll(ctx)))
+
+	assert.Equal(t, 0, len(w.poll(ctx)))
+}
+
+func TestWatchOOMHierarchicalEvents(t *testing.T) {
+	tree := newOOMTestTree(t)
+	tree.events("a", "memory.events", 0, 0)
+	tree.events("a/b", "memory.events", 0, 0)
+	w := tree.watcher("a", "/a/b/")
+	ctx := context.Background()
+
+	// a kill in /a/b shows in both memory.events
+	tree.events("a", "memory.events", 1, 1)
+	tree.events("a/b", "memory.events", 1, 1)
+	tree.vmstat(1)
+	events := w.poll(ctx)
+	assert.DeepEqual(t, map[string]uint64{"memory.events:/a/b": 1}, oomKillCounts(events))
+	assert.Equal(t, 2, len(events)) // oom and oom_kill of /a/b
+
+	// a kill in the unwatched /a/c only shows in /a
+	tree.events("a", "memory.events", 2, 2)
+	tree.vmstat(2)
+	assert.DeepEqual(t, map[string]uint64{"memory.events:/a": 1}, oomKillCounts(w.poll(ctx)))
+}
+
+func TestWatchOOMKmsg(t *testing.T) {
+	tree := newOOMTestTree(t)
+	tree.events("a", "memory.events", 0, 0)
+	w := tree.watcher("a")
+	ctx := context.Background()
+
+	// kmsg returns a record per read, as a seqpacket socket does
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_SEQPACKET|unix.SOCK_NONBLOCK, 0)
+	assert.Nil(t, err)
+	defer unix.Close(fds[0])
+	defer unix.Close(fds[1])
+	w.kmsg = fds[0]
+	kill := func(pid int, cgroup string) {
+		for _, record := range []string{
+			fmt.Sprintf("3,100,5000,-;oom-kill:constraint=CONSTRAINT_MEMCG,nodemask=(null),cpuset=/,mems_allowed=0,oom_memcg=/a,task_memcg=%s,task=worker,pid=%d,uid=1000", cgroup, pid),
+			fmt.Sprintf("3,101,5001,-;Memory cgroup out of memory: Killed process %d (worker) total-vm:2048kB, anon-rss:1024kB, file-rss:4kB, shmem-rss:0kB, UID:1000 pgtables:64kB oom_score_adj:0", pid),
+		} {
+			_, err := unix.Write(fds[1], []byte(record))
+			assert.Nil(t, err)
+		}
+	}
+
+	// the counters are bumped before the kill is logged
+	tree.events("a", "memory.events", 1, 1)
+	tree.vmstat(1)
+	kill(42, "/a/b")
+	events := w.poll(ctx)
+	assert.DeepEqual(t, map[string]uint64{"kmsg:/a/b": 1}, oomKillCounts(events))
+	e := events[0]
+	assert.Equal(t, int32(42), e.Pid)
+	assert.Equal(t, "worker", e.Comm)
+	assert.Equal(t, uint32(1000), e.UID)
+	assert.Equal(t, "/a", e.OOMCgroup)
+	assert.Equal(t, "CONSTRAINT_MEMCG", e.Constraint)
+	assert.Equal(t, uint64(1028*1024), e.RSS)
+
+	// logged before the counters were read back
+	kill(43, "/a")
+	assert.DeepEqual(t, map[string]uint64{"kmsg:/a": 1}, oomKillCounts(w.poll(ctx)))
+	tree.events("a", "memory.events", 2, 2)
+	tree.vmstat(2)
+	assert.DeepEqual(t, map[string]uint64{}, oomKillCounts(w.poll(ctx)))
+}
+
+func TestParseKilledProcessLine(t *testing.T) {
+	e, ok := parseKilledProcessLine("Out of memory: Killed process 1234 (my app) total-vm:1024kB, anon-rss:512kB, file-rss:8kB, shmem-rss:4kB, UID:0 pgtables:64kB oom_score_adj:-500")
+	assert.Equal(t, true, ok)
+	assert.Equal(t, int32(1234), e.Pid)
+	assert.Equal(t, "my app", e.Comm)
+	assert.Equal(t, uint64(1024*1024), e.TotalVM)
+	assert.Equal(t, uint64(524*1024), e.RSS)
+	assert.Equal(t, -500, e.OOMScoreAdj)
+
+	_, ok = parseKilledProcessLine("Killed process abc")
+	assert.Equal(t, false, ok)
+}
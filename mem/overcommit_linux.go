@@ -0,0 +1,117 @@
+//go:build linux
+
+package mem
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// OvercommitMode is the vm.overcommit_memory policy.
+type OvercommitMode uint64
+
+const (
+	// OvercommitHeuristic refuses only obvious overcommits (default).
+	OvercommitHeuristic OvercommitMode = 0
+	// OvercommitAlways never refuses an allocation.
+	OvercommitAlways OvercommitMode = 1
+	// OvercommitNever refuses allocations once CommittedAS would exceed
+	// CommitLimit, regardless of the free memory.
+	OvercommitNever OvercommitMode = 2
+)
+
+func (m OvercommitMode) String() string {
+	switch m {
+	case OvercommitHeuristic:
+		return "heuristic"
+	case OvercommitAlways:
+		return "always"
+	case OvercommitNever:
+		return "never"
+	}
+	return "unknown"
+}
+
+func (m OvercommitMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// OvercommitStat is the commit charge of the system against its commit
+// limit. Values are in bytes.
+//
+// CommitLimit is only enforced in OvercommitNever mode, where allocations
+// fail with ENOMEM once it is reached even though Available may still look
+// healthy. Unprivileged processes also cannot use the last AdminReserve
+// bytes, so Headroom is what is left to them and can be negative.
+// Refusing is set when the system is in strict mode and that headroom is
+// exhausted. On top of that, the kernel keeps up to UserReserve bytes, at
+// most 1/32 of the size of the process, from the allocations of every
+// unprivileged process.
+type OvercommitStat struct {
+	Mode         OvercommitMode `json:"mode"`
+	Ratio        uint64         `json:"ratio"`
+	Kbytes       uint64         `json:"kbytes"`
+	AdminReserve uint64         `json:"adminReserve"`
+	UserReserve  uint64         `json:"userReserve"`
+	CommitLimit  uint64         `json:"commitLimit"`
+	CommittedAS  uint64         `json:"committedAS"`
+	Headroom     int64          `json:"headroom"`
+	UsedPercent  float64        `json:"usedPercent"`
+	Strict       bool           `json:"strict"`
+	Refusing     bool           `json:"refusing"`
+}
+
+func (o OvercommitStat) String() string {
+	s, _ := json.Marshal(o)
+	return string(s)
+}
+
+// WouldRefuse reports whether an unprivileged allocation of size bytes would
+// currently be refused by the commit accounting. The whole UserReserve is
+// assumed to be kept from the process, as it is for processes of 32 times
+// UserReserve or more, so smaller processes may still succeed. It is always
+// false outside strict mode.
+func (o OvercommitStat) WouldRefuse(size uint64) bool {
+	return o.Strict && int64(size) >= o.Headroom-int64(o.UserReserve)
+}
+
+// Overcommit returns the overcommit policy and commit charge analysis.
+func Overcommit() (*OvercommitStat, error) {
+	return OvercommitWithContext(context.Background())
+}
+
+func OvercommitWithContext(ctx context.Context) (*OvercommitStat, error) {
+	vm := common.HostProcWithContext(ctx, "sys/vm")
+	mode, err := readSysUint(filepath.Join(vm, "overcommit_memory"))
+	if err != nil {
+		return nil, err
+	}
+	meminfo := readMeminfoKeys(common.HostProcWithContext(ctx, "meminfo"), "CommitLimit", "Committed_AS")
+
+	ret := &OvercommitStat{
+		Mode:        OvercommitMode(mode),
+		CommitLimit: meminfo["CommitLimit"],
+		CommittedAS: meminfo["Committed_AS"],
+		Strict:      OvercommitMode(mode) == OvercommitNever,
+	}
+	ret.Ratio, _ = readSysUint(filepath.Join(vm, "overcommit_ratio"))
+	if kbytes, err := readSysUint(filepath.Join(vm, "overcommit_kbytes")); err == nil {
+		ret.Kbytes = kbytes * 1024
+	}
+	if reserve, err := readSysUint(filepath.Join(vm, "admin_reserve_kbytes")); err == nil {
+		ret.AdminReserve = reserve * 1024
+	}
+	if reserve, err := readSysUint(filepath.Join(vm, "user_reserve_kbytes")); err == nil {
+		ret.UserReserve = reserve * 1024
+	}
+
+	ret.Headroom = int64(ret.CommitLimit) - int64(ret.AdminReserve) - int64(ret.CommittedAS)
+	if ret.CommitLimit > 0 {
+		ret.UsedPercent = float64(ret.CommittedAS) / float64(ret.CommitLimit) * 100.0
+	}
+	ret.Refusing = ret.Strict && ret.Headroom <= 0
+	return ret, nil
+}
@@ -0,0 +1,75 @@
+//go:build linux
+
+package mem
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func writeOvercommitFiles(t *testing.T, proc string, mode string, commitLimitKB, committedKB string) {
+	t.Helper()
+	writeTestFile(t, filepath.Join(proc, "sys/vm/overcommit_memory"), mode+"\n")
+	writeTestFile(t, filepath.Join(proc, "sys/vm/overcommit_ratio"), "50\n")
+	writeTestFile(t, filepath.Join(proc, "sys/vm/overcommit_kbytes"), "0\n")
+	writeTestFile(t, filepath.Join(proc, "sys/vm/admin_reserve_kbytes"), "8192\n")
+	writeTestFile(t, filepath.Join(proc, "sys/vm/user_reserve_kbytes"), "131072\n")
+	writeTestFile(t, filepath.Join(proc, "meminfo"), "MemTotal:        8388608 kB\nMemFree:         4194304 kB\n"+
+		"CommitLimit:     "+commitLimitKB+" kB\nCommitted_AS:    "+committedKB+" kB\n")
+}
+
+func TestOvercommitStrict(t *testing.T) {
+	proc := t.TempDir()
+	t.Setenv("HOST_PROC", proc)
+	// 4 GiB limit, 3 GiB committed
+	writeOvercommitFiles(t, proc, "2", "4194304", "3145728")
+
+	o, err := Overcommit()
+	assert.Nil(t, err)
+	assert.Equal(t, OvercommitNever, o.Mode)
+	assert.Equal(t, true, o.Strict)
+	assert.Equal(t, uint64(50), o.Ratio)
+	assert.Equal(t, uint64(8<<20), o.AdminReserve)
+	assert.Equal(t, uint64(128<<20), o.UserReserve)
+	assert.Equal(t, uint64(4<<30), o.CommitLimit)
+	assert.Equal(t, uint64(3<<30), o.CommittedAS)
+	assert.Equal(t, 75.0, o.UsedPercent)
+	assert.Equal(t, int64(1<<30-8<<20), o.Headroom)
+	assert.Equal(t, false, o.Refusing)
+
+	// the user reserve is kept from the headroom
+	assert.Equal(t, false, o.WouldRefuse(512<<20))
+	assert.Equal(t, false, o.WouldRefuse(1<<30-136<<20-1))
+	assert.Equal(t, true, o.WouldRefuse(1<<30-136<<20))
+	assert.Equal(t, true, o.WouldRefuse(1<<30))
+}
+
+func TestOvercommitExhausted(t *testing.T) {
+	proc := t.TempDir()
+	t.Setenv("HOST_PROC", proc)
+	// only the admin reserve is left
+	writeOvercommitFiles(t, proc, "2", "4194304", "4190208")
+
+	o, err := Overcommit()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-4<<20), o.Headroom)
+	assert.Equal(t, true, o.Refusing)
+	assert.Equal(t, true, o.WouldRefuse(1))
+}
+
+func TestOvercommitHeuristic(t *testing.T) {
+	proc := t.TempDir()
+	t.Setenv("HOST_PROC", proc)
+	// committed above the limit, which is not enforced
+	writeOvercommitFiles(t, proc, "0", "4194304", "8388608")
+
+	o, err := Overcommit()
+	assert.Nil(t, err)
+	assert.Equal(t, "heuristic", o.Mode.String())
+	assert.Equal(t, false, o.Strict)
+	assert.Equal(t, 200.0, o.UsedPercent)
+	assert.Equal(t, false, o.Refusing)
+	assert.Equal(t, false, o.WouldRefuse(1<<40))
+}
@@ -0,0 +1,200 @@
+//go:build linux
+
+package mem
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// SlabCacheStat describes a kernel slab cache. ActiveBytes is the memory
+// held by allocated objects, TotalBytes the memory of every slab of the
+// cache, including free objects.
+//
+// Reclaimable is only known when /sys/kernel/slab is available (SLUB);
+// caches such as dentry and inode_cache are reclaimable under pressure.
+type SlabCacheStat struct {
+	Name           string `json:"name"`
+	ActiveObjects  uint64 `json:"activeObjects"`
+	TotalObjects   uint64 `json:"totalObjects"`
+	ObjectSize     uint64 `json:"objectSize"`
+	ObjectsPerSlab uint64 `json:"objectsPerSlab"`
+	PagesPerSlab   uint64 `json:"pagesPerSlab"`
+	Slabs          uint64 `json:"slabs"`
+	ActiveBytes    uint64 `json:"activeBytes"`
+	TotalBytes     uint64 `json:"totalBytes"`
+	Reclaimable    bool   `json:"reclaimable"`
+}
+
+// SlabCacheDelta is the growth of a slab cache between two samples.
+type SlabCacheDelta struct {
+	Name       string `json:"name"`
+	Objects    int64  `json:"objects"`
+	TotalBytes int64  `json:"totalBytes"`
+}
+
+func (s SlabCacheStat) String() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s SlabCacheDelta) String() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// SlabCaches returns every slab cache sorted by decreasing TotalBytes.
+// /proc/slabinfo is used when readable, usually only by root, otherwise the
+// caches are read from /sys/kernel/slab.
+func SlabCaches() ([]SlabCacheStat, error) {
+	return SlabCachesWithContext(context.Background())
+}
+
+func SlabCachesWithContext(ctx context.Context) ([]SlabCacheStat, error) {
+	pageSize := uint64(os.Getpagesize())
+	sysSlab := common.HostSysWithContext(ctx, "kernel/slab")
+
+	ret, err := readSlabinfo(common.HostProcWithContext(ctx, "slabinfo"), pageSize)
+	if err != nil {
+		ret, err = readSysSlab(sysSlab, pageSize)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for i := range ret {
+			if v, err := readSysSlabUint(filepath.Join(sysSlab, ret[i].Name, "reclaim_account")); err == nil {
+				ret[i].Reclaimable = v == 1
+			}
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].TotalBytes > ret[j].TotalBytes })
+	return ret, nil
+}
+
+func readSlabinfo(filename string, pageSize uint64) ([]SlabCacheStat, error) {
+	lines, err := common.ReadLines(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []SlabCacheStat
+	for _, line := range lines {
+		if strings.HasPrefix(line, "slabinfo -") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// name active_objs num_objs objsize objperslab pagesperslab : tunables ... : slabdata active_slabs num_slabs sharedavail
+		fields := strings.Fields(line)
+		if len(fields) < 15 {
+			continue
+		}
+		values := make([]uint64, 0, 7)
+		for _, f := range append(fields[1:6:6], fields[13:15]...) {
+			v, err := strconv.ParseUint(f, 10, 64)
+			if err != nil {
+				break
+			}
+			values = append(values, v)
+		}
+		if len(values) != 7 {
+			continue
+		}
+		s := SlabCacheStat{
+			Name:           fields[0],
+			ActiveObjects:  values[0],
+			TotalObjects:   values[1],
+			ObjectSize:     values[2],
+			ObjectsPerSlab: values[3],
+			PagesPerSlab:   values[4],
+			Slabs:          values[6],
+		}
+		s.ActiveBytes = s.ActiveObjects * s.ObjectSize
+		s.TotalBytes = s.Slabs * s.PagesPerSlab * pageSize
+		ret = append(ret, s)
+	}
+	return ret, nil
+}
+
+// readSysSlab reads the SLUB caches of /sys/kernel/slab. Merged caches are
+// symlinks to the same ":xxx" directory and are reported once, under the
+// first name.
+func readSysSlab(dir string, pageSize uint64) ([]SlabCacheStat, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var ret []SlabCacheStat
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ":") {
+			continue // anonymous merged cache, listed through its aliases
+		}
+		path := filepath.Join(dir, name)
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil || seen[target] {
+			continue
+		}
+		seen[target] = true
+
+		s := SlabCacheStat{Name: name}
+		s.ActiveObjects, _ = readSysSlabUint(filepath.Join(path, "objects"))
+		s.TotalObjects, _ = readSysSlabUint(filepath.Join(path, "total_objects"))
+		s.ObjectSize, _ = readSysSlabUint(filepath.Join(path, "object_size"))
+		s.ObjectsPerSlab, _ = readSysSlabUint(filepath.Join(path, "objs_per_slab"))
+		s.Slabs, _ = readSysSlabUint(filepath.Join(path, "slabs"))
+		order, _ := readSysSlabUint(filepath.Join(path, "order"))
+		s.PagesPerSlab = uint64(1) << order
+		if v, err := readSysSlabUint(filepath.Join(path, "reclaim_account")); err == nil {
+			s.Reclaimable = v == 1
+		}
+		s.ActiveBytes = s.ActiveObjects * s.ObjectSize
+		s.TotalBytes = s.Slabs * s.PagesPerSlab * pageSize
+		ret = append(ret, s)
+	}
+	return ret, nil
+}
+
+// readSysSlabUint reads the leading number of a /sys/kernel/slab attribute,
+// ignoring the per node breakdown, e.g. "16884 N0=16884".
+func readSysSlabUint(filename string) (uint64, error) {
+	lines, err := common.ReadLines(filename)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(strings.Join(lines, " "))
+	if len(fields) == 0 {
+		return 0, os.ErrNotExist
+	}
+	return strconv.ParseUint(fields[0], 10, 64)
+}
+
+// SlabCacheDeltas returns the growth of every cache present in cur since
+// prev, sorted by decreasing TotalBytes growth. Caches that appeared since
+// prev are compared against an empty cache.
+func SlabCacheDeltas(prev, cur []SlabCacheStat) []SlabCacheDelta {
+	last := make(map[string]SlabCacheStat, len(prev))
+	for _, s := range prev {
+		last[s.Name] = s
+	}
+
+	ret := make([]SlabCacheDelta, 0, len(cur))
+	for _, s := range cur {
+		p := last[s.Name]
+		ret = append(ret, SlabCacheDelta{
+			Name:       s.Name,
+			Objects:    int64(s.ActiveObjects) - int64(p.ActiveObjects),
+			TotalBytes: int64(s.TotalBytes) - int64(p.TotalBytes),
+		})
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].TotalBytes > ret[j].TotalBytes })
+	return ret
+}
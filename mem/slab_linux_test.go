@@ -0,0 +1,101 @@
+//go:build linux
+
+package mem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+const procSlabinfo = `slabinfo - version: 2.1
+# name            <active_objs> <num_objs> <objsize> <objperslab> <pagesperslab> : tunables <limit> <batchcount> <sharedfactor> : slabdata <active_slabs> <num_slabs> <sharedavail>
+kmalloc-64          6400   6400     64   64    1 : tunables    0    0    0 : slabdata    100    100      0
+dentry            100000 105000    192   21    1 : tunables    0    0    0 : slabdata   5000   5000      0
+ext4_inode_cache   20000  20000   1080   30    8 : tunables    0    0    0 : slabdata    666    667      0
+broken               abc    100     64   64    1 : tunables    0    0    0 : slabdata      1      1      0
+`
+
+func writeTestFile(t *testing.T, name, content string) {
+	t.Helper()
+	assert.Nil(t, os.MkdirAll(filepath.Dir(name), 0o755))
+	assert.Nil(t, os.WriteFile(name, []byte(content), 0o644))
+}
+
+func TestSlabCachesSlabinfo(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "proc", "slabinfo"), procSlabinfo)
+	writeTestFile(t, filepath.Join(root, "sys", "kernel", "slab", "dentry", "reclaim_account"), "1\n")
+	t.Setenv("HOST_PROC", filepath.Join(root, "proc"))
+	t.Setenv("HOST_SYS", filepath.Join(root, "sys"))
+
+	pageSize := uint64(os.Getpagesize())
+	caches, err := SlabCaches()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(caches))
+
+	// sorted by TotalBytes: 667*8 pages, 5000 pages, 100 pages
+	inode := caches[0]
+	assert.Equal(t, "ext4_inode_cache", inode.Name)
+	assert.Equal(t, uint64(8), inode.PagesPerSlab)
+	assert.Equal(t, uint64(667*8)*pageSize, inode.TotalBytes)
+	assert.Equal(t, uint64(20000*1080), inode.ActiveBytes)
+	assert.Equal(t, false, inode.Reclaimable)
+
+	dentry := caches[1]
+	assert.Equal(t, "dentry", dentry.Name)
+	assert.Equal(t, uint64(100000), dentry.ActiveObjects)
+	assert.Equal(t, uint64(105000), dentry.TotalObjects)
+	assert.Equal(t, uint64(21), dentry.ObjectsPerSlab)
+	assert.Equal(t, true, dentry.Reclaimable)
+}
+
+func TestSlabCachesSysfs(t *testing.T) {
+	root := t.TempDir()
+	slab := filepath.Join(root, "sys", "kernel", "slab")
+	merged := filepath.Join(slab, ":a-0000192")
+	for name, value := range map[string]string{
+		"objects":         "2000 N0=1500 N1=500\n",
+		"total_objects":   "2100 N0=1600 N1=500\n",
+		"object_size":     "192\n",
+		"objs_per_slab":   "21\n",
+		"slabs":           "100 N0=80 N1=20\n",
+		"order":           "0\n",
+		"reclaim_account": "1\n",
+	} {
+		writeTestFile(t, filepath.Join(merged, name), value)
+	}
+	assert.Nil(t, os.Symlink(merged, filepath.Join(slab, "dentry")))
+	assert.Nil(t, os.Symlink(merged, filepath.Join(slab, "vm_area_struct")))
+	t.Setenv("HOST_PROC", filepath.Join(root, "proc"))
+	t.Setenv("HOST_SYS", filepath.Join(root, "sys"))
+
+	caches, err := SlabCaches()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(caches))
+	assert.Equal(t, "dentry", caches[0].Name)
+	assert.Equal(t, uint64(2000), caches[0].ActiveObjects)
+	assert.Equal(t, uint64(2100), caches[0].TotalObjects)
+	assert.Equal(t, uint64(1), caches[0].PagesPerSlab)
+	assert.Equal(t, uint64(100*os.Getpagesize()), caches[0].TotalBytes)
+	assert.Equal(t, true, caches[0].Reclaimable)
+}
+
+func TestSlabCacheDeltas(t *testing.T) {
+	prev := []SlabCacheStat{
+		{Name: "dentry", ActiveObjects: 100, TotalBytes: 4096},
+		{Name: "kmalloc-64", ActiveObjects: 50, TotalBytes: 8192},
+	}
+	cur := []SlabCacheStat{
+		{Name: "dentry", ActiveObjects: 300, TotalBytes: 12288},
+		{Name: "kmalloc-64", ActiveObjects: 10, TotalBytes: 4096},
+		{Name: "new", ActiveObjects: 1, TotalBytes: 4096},
+	}
+	assert.DeepEqual(t, []SlabCacheDelta{
+		{Name: "dentry", Objects: 200, TotalBytes: 8192},
+		{Name: "new", Objects: 1, TotalBytes: 4096},
+		{Name: "kmalloc-64", Objects: -40, TotalBytes: -4096},
+	}, SlabCacheDeltas(prev, cur))
+}
@@ -0,0 +1,236 @@
+//go:build linux
+
+package mem
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// diskstats column indexes
+const (
+	diskMajorCol          = 0
+	diskMinorCol          = 1
+	diskNameCol           = 2
+	diskReadsCol          = 3
+	diskSectorsReadCol    = 5
+	diskWritesCol         = 7
+	diskSectorsWrittenCol = 9
+)
+
+// SwapDeviceActivity is the IO rate of a swap device over an interval. The
+// rates of swap files are not known and stay at 0.
+type SwapDeviceActivity struct {
+	Name             string  `json:"name"`
+	BlockDevice      string  `json:"blockDevice,omitempty"`
+	ReadBytesPerSec  float64 `json:"readBytesPerSec"`
+	WriteBytesPerSec float64 `json:"writeBytesPerSec"`
+}
+
+// SwapActivityStat is the swapping activity of the host over an interval.
+// The swap-in and swap-out rates come from pswpin and pswpout in
+// /proc/vmstat, in pages per second.
+type SwapActivityStat struct {
+	Interval           time.Duration        `json:"interval"`
+	SwapInPerSec       float64              `json:"swapInPerSec"`
+	SwapOutPerSec      float64              `json:"swapOutPerSec"`
+	SwapInBytesPerSec  float64              `json:"swapInBytesPerSec"`
+	SwapOutBytesPerSec float64              `json:"swapOutBytesPerSec"`
+	Devices            []SwapDeviceActivity `json:"devices"`
+	Thrashing          bool                 `json:"thrashing"`
+}
+
+func (s SwapActivityStat) String() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// fillSwapDevicesIO resolves the block device behind every swap area and
+// copies its counters from /proc/diskstats. Errors are ignored, leaving the
+// fields empty, as swap files on network or virtual file systems have no
+// block device.
+func fillSwapDevicesIO(ctx context.Context, swapDevices []*SwapDevice) {
+	lines, err := common.ReadLines(common.HostProcWithContext(ctx, "diskstats"))
+	if err != nil {
+		return
+	}
+	disks := make(map[uint64][]string, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) <= diskSectorsWrittenCol {
+			continue
+		}
+		major, err1 := strconv.ParseUint(fields[diskMajorCol], 10, 32)
+		minor, err2 := strconv.ParseUint(fields[diskMinorCol], 10, 32)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		disks[unix.Mkdev(uint32(major), uint32(minor))] = fields
+	}
+
+	for _, swapDevice := range swapDevices {
+		var st unix.Stat_t
+		if err := unix.Stat(hostSwapPath(ctx, swapDevice.Name), &st); err != nil {
+			continue
+		}
+		dev := st.Dev // swap file: the device of its file system
+		if swapDevice.Type != "file" {
+			dev = st.Rdev
+		}
+		fields, ok := disks[dev]
+		if !ok {
+			continue
+		}
+		swapDevice.BlockDevice = fields[diskNameCol]
+		if swapDevice.Type == "file" {
+			continue
+		}
+		swapDevice.ReadIOs, _ = strconv.ParseUint(fields[diskReadsCol], 10, 64)
+		swapDevice.WriteIOs, _ = strconv.ParseUint(fields[diskWritesCol], 10, 64)
+		sectorsRead, _ := strconv.ParseUint(fields[diskSectorsReadCol], 10, 64)
+		sectorsWritten, _ := strconv.ParseUint(fields[diskSectorsWrittenCol], 10, 64)
+		// diskstats sectors are always 512 bytes
+		swapDevice.ReadBytes = sectorsRead * 512
+		swapDevice.WrittenBytes = sectorsWritten * 512
+	}
+}
+
+// hostSwapPath returns where the swap area name of /proc/swaps, a path of
+// the host, is found when HOST_DEV or HOST_ROOT are set.
+func hostSwapPath(ctx context.Context, name string) string {
+	if dev, ok := strings.CutPrefix(name, "/dev/"); ok {
+		return common.HostDevWithContext(ctx, dev)
+	}
+	return common.HostRootWithContext(ctx, name)
+}
+
+// unescapeSwapName decodes the octal escapes of the space, tab, newline
+// and backslash characters in /proc/swaps names, e.g. "\040" for a space.
+func unescapeSwapName(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// SwapThrashingDetector keeps a rolling window of swap-in and swap-out rates
+// and reports thrashing when both stay above their thresholds, i.e. pages
+// are written out only to be read back shortly after. It is safe for
+// concurrent use.
+type SwapThrashingDetector struct {
+	mu         sync.Mutex
+	minSwapIn  float64
+	minSwapOut float64
+	samples    *common.Window[bool]
+}
+
+// NewSwapThrashingDetector returns a detector over the last window samples.
+// minSwapIn and minSwapOut are rates in pages per second.
+func NewSwapThrashingDetector(window int, minSwapIn, minSwapOut float64) *SwapThrashingDetector {
+	return &SwapThrashingDetector{
+		minSwapIn:  minSwapIn,
+		minSwapOut: minSwapOut,
+		samples:    common.NewWindow[bool](window),
+	}
+}
+
+// Add records a pair of swap-in and swap-out rates, in pages per second,
+// and reports whether swapping is now sustained enough to be thrashing.
+func (d *SwapThrashingDetector) Add(swapIn, swapOut float64) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.samples.Add(swapIn > d.minSwapIn && swapOut > d.minSwapOut)
+	return d.samples.Sustained(func(above bool) bool { return above })
+}
+
+// Sample measures the swap activity over interval, records it and returns
+// the resulting report.
+func (d *SwapThrashingDetector) Sample(interval time.Duration) (*SwapActivityStat, error) {
+	return d.SampleWithContext(context.Background(), interval)
+}
+
+func (d *SwapThrashingDetector) SampleWithContext(ctx context.Context, interval time.Duration) (*SwapActivityStat, error) {
+	ret, err := SwapActivityWithContext(ctx, interval)
+	if err != nil {
+		return nil, err
+	}
+	ret.Thrashing = d.Add(ret.SwapInPerSec, ret.SwapOutPerSec)
+	return ret, nil
+}
+
+// SwapActivity measures the swap-in and swap-out rates and the IO of every
+// swap device over interval. Thrashing is left unset, use a
+// SwapThrashingDetector to track it across samples.
+func SwapActivity(interval time.Duration) (*SwapActivityStat, error) {
+	return SwapActivityWithContext(context.Background(), interval)
+}
+
+func SwapActivityWithContext(ctx context.Context, interval time.Duration) (*SwapActivityStat, error) {
+	vmstat1, err := VMStatWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	devices1, err := SwapDevicesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := common.Sleep(ctx, interval); err != nil {
+		return nil, err
+	}
+
+	vmstat2, err := VMStatWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	devices2, err := SwapDevicesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &SwapActivityStat{
+		Interval:      vmstat2.Timestamp.Sub(vmstat1.Timestamp),
+		SwapInPerSec:  vmstat2.Rate(vmstat1, "pswpin"),
+		SwapOutPerSec: vmstat2.Rate(vmstat1, "pswpout"),
+	}
+	ret.SwapInBytesPerSec = ret.SwapInPerSec * float64(vmstat2.PageSize)
+	ret.SwapOutBytesPerSec = ret.SwapOutPerSec * float64(vmstat2.PageSize)
+
+	last := make(map[string]*SwapDevice, len(devices1))
+	for _, d := range devices1 {
+		last[d.Name] = d
+	}
+	elapsed := ret.Interval.Seconds()
+	for _, d := range devices2 {
+		activity := SwapDeviceActivity{Name: d.Name, BlockDevice: d.BlockDevice}
+		if p, ok := last[d.Name]; ok && elapsed > 0 {
+			if d.ReadBytes >= p.ReadBytes {
+				activity.ReadBytesPerSec = float64(d.ReadBytes-p.ReadBytes) / elapsed
+			}
+			if d.WrittenBytes >= p.WrittenBytes {
+				activity.WriteBytesPerSec = float64(d.WrittenBytes-p.WrittenBytes) / elapsed
+			}
+		}
+		ret.Devices = append(ret.Devices, activity)
+	}
+	return ret, nil
+}
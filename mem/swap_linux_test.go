@@ -0,0 +1,78 @@
+//go:build linux
+
+package mem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func TestFillSwapDevicesIO(t *testing.T) {
+	proc, root, dev := t.TempDir(), t.TempDir(), t.TempDir()
+	swapFile := filepath.Join(root, "swap file")
+	writeTestFile(t, swapFile, "")
+	// the host /dev/null seen through HOST_DEV
+	assert.Nil(t, os.Symlink("/dev/null", filepath.Join(dev, "null")))
+
+	var file, null unix.Stat_t
+	assert.Nil(t, unix.Stat(swapFile, &file))
+	assert.Nil(t, unix.Stat("/dev/null", &null))
+	diskstats := fmt.Sprintf(
+		"%4d %7d fsdisk 100 0 2000 0 50 0 4000 0 0 0 0\n%4d %7d nulldev 10 0 200 0 5 0 400 0 0 0 0\n",
+		unix.Major(file.Dev), unix.Minor(file.Dev),
+		unix.Major(null.Rdev), unix.Minor(null.Rdev),
+	)
+	writeTestFile(t, filepath.Join(proc, "diskstats"), diskstats)
+	writeTestFile(t, filepath.Join(proc, "swaps"), "Filename\tType\tSize\tUsed\tPriority\n"+
+		"/swap\\040file                           file\t\t1048572\t\t0\t\t-2\n"+
+		"/dev/null                               partition\t2097148\t\t1024\t\t10\n"+
+		"/dev/missing                            partition\t2097148\t\t0\t\t-3\n")
+	t.Setenv("HOST_PROC", proc)
+	t.Setenv("HOST_ROOT", root)
+	t.Setenv("HOST_DEV", dev)
+
+	devices, err := SwapDevices()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(devices))
+
+	// the IO of the file system is not the IO of the swap file
+	assert.Equal(t, "/swap file", devices[0].Name)
+	assert.Equal(t, "fsdisk", devices[0].BlockDevice)
+	assert.Equal(t, uint64(0), devices[0].ReadIOs)
+	assert.Equal(t, uint64(0), devices[0].ReadBytes)
+	assert.Equal(t, uint64(0), devices[0].WrittenBytes)
+
+	assert.Equal(t, "/dev/null", devices[1].Name)
+	assert.Equal(t, 10, devices[1].Priority)
+	assert.Equal(t, "nulldev", devices[1].BlockDevice)
+	assert.Equal(t, uint64(10), devices[1].ReadIOs)
+	assert.Equal(t, uint64(5), devices[1].WriteIOs)
+	assert.Equal(t, uint64(200*512), devices[1].ReadBytes)
+	assert.Equal(t, uint64(400*512), devices[1].WrittenBytes)
+
+	assert.Equal(t, "", devices[2].BlockDevice)
+}
+
+func TestUnescapeSwapName(t *testing.T) {
+	assert.Equal(t, "/swapfile", unescapeSwapName("/swapfile"))
+	assert.Equal(t, "/mnt/my swap\tfile\\", unescapeSwapName(`/mnt/my\040swap\011file\134`))
+	assert.Equal(t, `/bad\9`, unescapeSwapName(`/bad\9`))
+}
+
+func TestSwapThrashingDetector(t *testing.T) {
+	d := NewSwapThrashingDetector(4, 10, 10)
+	assert.Equal(t, false, d.Add(100, 100))
+	assert.Equal(t, false, d.Add(100, 100))
+	assert.Equal(t, false, d.Add(100, 0))
+	// 3 of 4 samples above both thresholds
+	assert.Equal(t, true, d.Add(100, 100))
+	assert.Equal(t, true, d.Add(100, 100))
+	assert.Equal(t, false, d.Add(0, 100))
+	assert.Equal(t, false, d.Add(0, 0))
+}
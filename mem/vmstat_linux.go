@@ -0,0 +1,242 @@
+//go:build linux
+
+package mem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// VMStatStat is a sample of /proc/vmstat.
+//
+// Raw holds every key of the file. The typed fields are copies of the most
+// useful counters; most of them count pages, use Bytes to convert them.
+// Counters split by zone or type on some kernels, e.g. allocstall_normal
+// or workingset_refault_anon, are summed into a single typed field.
+type VMStatStat struct {
+	Timestamp time.Time         `json:"timestamp"`
+	PageSize  uint64            `json:"pageSize"`
+	Raw       map[string]uint64 `json:"raw"`
+
+	// paging and swapping
+	PgpgIn     uint64 `json:"pgpgin"` // KiB, not pages
+	PgpgOut    uint64 `json:"pgpgout"`
+	PswpIn     uint64 `json:"pswpin"`
+	PswpOut    uint64 `json:"pswpout"`
+	PgFault    uint64 `json:"pgfault"`
+	PgMajFault uint64 `json:"pgmajfault"`
+
+	// reclaim
+	PgscanKswapd      uint64 `json:"pgscanKswapd"`
+	PgscanDirect      uint64 `json:"pgscanDirect"`
+	PgstealKswapd     uint64 `json:"pgstealKswapd"`
+	PgstealDirect     uint64 `json:"pgstealDirect"`
+	AllocStall        uint64 `json:"allocstall"`
+	WorkingsetRefault uint64 `json:"workingsetRefault"`
+	OomKill           uint64 `json:"oomKill"`
+
+	// compaction
+	CompactStall          uint64 `json:"compactStall"`
+	CompactFail           uint64 `json:"compactFail"`
+	CompactSuccess        uint64 `json:"compactSuccess"`
+	CompactMigrateScanned uint64 `json:"compactMigrateScanned"`
+	CompactFreeScanned    uint64 `json:"compactFreeScanned"`
+	CompactIsolated       uint64 `json:"compactIsolated"`
+	CompactDaemonWake     uint64 `json:"compactDaemonWake"`
+
+	// transparent huge pages
+	ThpFaultAlloc          uint64 `json:"thpFaultAlloc"`
+	ThpFaultFallback       uint64 `json:"thpFaultFallback"`
+	ThpCollapseAlloc       uint64 `json:"thpCollapseAlloc"`
+	ThpCollapseAllocFailed uint64 `json:"thpCollapseAllocFailed"`
+	ThpSplitPage           uint64 `json:"thpSplitPage"`
+	ThpSwpout              uint64 `json:"thpSwpout"`
+
+	// NUMA
+	NumaHit           uint64 `json:"numaHit"`
+	NumaMiss          uint64 `json:"numaMiss"`
+	NumaForeign       uint64 `json:"numaForeign"`
+	NumaInterleave    uint64 `json:"numaInterleave"`
+	NumaLocal         uint64 `json:"numaLocal"`
+	NumaOther         uint64 `json:"numaOther"`
+	NumaHintFaults    uint64 `json:"numaHintFaults"`
+	NumaPagesMigrated uint64 `json:"numaPagesMigrated"`
+}
+
+func (v VMStatStat) String() string {
+	s, _ := json.Marshal(v)
+	return string(s)
+}
+
+// Bytes converts a number of pages to bytes using the system page size.
+func (v VMStatStat) Bytes(pages uint64) uint64 {
+	return pages * v.PageSize
+}
+
+// VMStat returns every counter of /proc/vmstat.
+func VMStat() (*VMStatStat, error) {
+	return VMStatWithContext(context.Background())
+}
+
+func VMStatWithContext(ctx context.Context) (*VMStatStat, error) {
+	lines, err := common.ReadLines(common.HostProcWithContext(ctx, "vmstat"))
+	if err != nil {
+		return nil, err
+	}
+	return parseVMStat(lines), nil
+}
+
+func parseVMStat(lines []string) *VMStatStat {
+	ret := &VMStatStat{
+		Timestamp: time.Now(),
+		PageSize:  uint64(os.Getpagesize()),
+		Raw:       make(map[string]uint64, len(lines)),
+	}
+	for _, l := range lines {
+		fields := strings.Fields(l)
+		if len(fields) < 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		ret.Raw[fields[0]] = value
+	}
+
+	for key, field := range map[string]*uint64{
+		"pgpgin":                    &ret.PgpgIn,
+		"pgpgout":                   &ret.PgpgOut,
+		"pswpin":                    &ret.PswpIn,
+		"pswpout":                   &ret.PswpOut,
+		"pgfault":                   &ret.PgFault,
+		"pgmajfault":                &ret.PgMajFault,
+		"pgscan_kswapd":             &ret.PgscanKswapd,
+		"pgscan_direct":             &ret.PgscanDirect,
+		"pgsteal_kswapd":            &ret.PgstealKswapd,
+		"pgsteal_direct":            &ret.PgstealDirect,
+		"allocstall":                &ret.AllocStall,
+		"workingset_refault":        &ret.WorkingsetRefault,
+		"oom_kill":                  &ret.OomKill,
+		"compact_stall":             &ret.CompactStall,
+		"compact_fail":              &ret.CompactFail,
+		"compact_success":           &ret.CompactSuccess,
+		"compact_migrate_scanned":   &ret.CompactMigrateScanned,
+		"compact_free_scanned":      &ret.CompactFreeScanned,
+		"compact_isolated":          &ret.CompactIsolated,
+		"compact_daemon_wake":       &ret.CompactDaemonWake,
+		"thp_fault_alloc":           &ret.ThpFaultAlloc,
+		"thp_fault_fallback":        &ret.ThpFaultFallback,
+		"thp_collapse_alloc":        &ret.ThpCollapseAlloc,
+		"thp_collapse_alloc_failed": &ret.ThpCollapseAllocFailed,
+		"thp_split_page":            &ret.ThpSplitPage,
+		"thp_swpout":                &ret.ThpSwpout,
+		"numa_hit":                  &ret.NumaHit,
+		"numa_miss":                 &ret.NumaMiss,
+		"numa_foreign":              &ret.NumaForeign,
+		"numa_interleave":           &ret.NumaInterleave,
+		"numa_local":                &ret.NumaLocal,
+		"numa_other":                &ret.NumaOther,
+		"numa_hint_faults":          &ret.NumaHintFaults,
+		"numa_pages_migrated":       &ret.NumaPagesMigrated,
+	} {
+		*field = ret.sum(key)
+	}
+	return ret
+}
+
+// vmstatSplitSuffixes are the zone (dma, dma32, normal, high, movable) and
+// LRU type (anon, file) suffixes a counter is split by on some kernels.
+var vmstatSplitSuffixes = []string{"_dma", "_dma32", "_normal", "_high", "_movable", "_anon", "_file"}
+
+// sum returns the counter named key or, when the kernel splits it, the sum
+// of its split counters, e.g. pgscan_kswapd_normal and pgscan_kswapd_dma
+// before Linux 4.8. Other counters sharing the prefix, such as
+// thp_swpout_fallback for thp_swpout, are not counted.
+func (v VMStatStat) sum(key string) uint64 {
+	if value, ok := v.Raw[key]; ok {
+		return value
+	}
+	var ret uint64
+	for _, suffix := range vmstatSplitSuffixes {
+		ret += v.Raw[key+suffix]
+	}
+	return ret
+}
+
+// vmstatNrCounters are the nr_ keys of /proc/vmstat that count events,
+// unlike the other nr_ keys which are gauges of the current number of
+// pages. nr_tlb_* are also events.
+var vmstatNrCounters = []string{
+	"nr_dirtied",
+	"nr_written",
+	"nr_throttled_written",
+	"nr_vmscan_write",
+	"nr_vmscan_immediate_reclaim",
+	"nr_foll_pin_acquired",
+	"nr_foll_pin_released",
+}
+
+// vmstatGauges are the gauges of /proc/vmstat without the nr_ prefix.
+var vmstatGauges = []string{"workingset_nodes", "dirty_threshold", "dirty_background_threshold"}
+
+// IsVMStatCounter reports whether key is a monotonic event counter, such as
+// pgfault or pswpin, rather than a gauge such as nr_free_pages. Only
+// counters have a meaningful rate.
+func IsVMStatCounter(key string) bool {
+	if strings.HasPrefix(key, "nr_") {
+		return strings.HasPrefix(key, "nr_tlb_") || common.StringsHas(vmstatNrCounters, key)
+	}
+	return !common.StringsHas(vmstatGauges, key)
+}
+
+// VMStatRates returns the per second rate of every event counter present
+// in both samples. Gauges, see IsVMStatCounter, are left out and counters
+// that went backwards are reported as 0.
+func VMStatRates(prev, cur *VMStatStat) (map[string]float64, error) {
+	if prev == nil || cur == nil {
+		return nil, errors.New("vmstat samples must not be nil")
+	}
+	elapsed := cur.Timestamp.Sub(prev.Timestamp).Seconds()
+	if elapsed <= 0 {
+		return nil, errors.New("vmstat samples must be taken at increasing times")
+	}
+
+	ret := make(map[string]float64, len(cur.Raw))
+	for key, value := range cur.Raw {
+		if !IsVMStatCounter(key) {
+			continue
+		}
+		last, ok := prev.Raw[key]
+		if !ok {
+			continue
+		}
+		if value < last {
+			ret[key] = 0
+			continue
+		}
+		ret[key] = float64(value-last) / elapsed
+	}
+	return ret, nil
+}
+
+// Rate returns the per second rate of a single counter since prev, using
+// the same rules as VMStatRates. It is 0 for gauges.
+func (v VMStatStat) Rate(prev *VMStatStat, key string) float64 {
+	if prev == nil || !IsVMStatCounter(key) {
+		return 0
+	}
+	elapsed := v.Timestamp.Sub(prev.Timestamp).Seconds()
+	cur, last := v.sum(key), prev.sum(key)
+	if elapsed <= 0 || cur < last {
+		return 0
+	}
+	return float64(cur-last) / elapsed
+}
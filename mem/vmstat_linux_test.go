@@ -0,0 +1,76 @@
+//go:build linux
+
+package mem
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func TestParseVMStat(t *testing.T) {
+	// counters of a 4.4 kernel, split by zone, with no thp_swpout
+	const vmstat = `nr_free_pages 12345
+pgpgin 1000
+pswpin 20
+pgscan_kswapd_dma 1
+pgscan_kswapd_dma32 10
+pgscan_kswapd_normal 100
+pgscan_kswapd_movable 1000
+allocstall_normal 3
+allocstall_movable 4
+workingset_refault 77
+thp_swpout_fallback 9
+oom_kill 2
+bogus
+nr_dirty x
+`
+	v := parseVMStat(strings.Split(vmstat, "\n"))
+	assert.Equal(t, uint64(12345), v.Raw["nr_free_pages"])
+	assert.Equal(t, uint64(1000), v.PgpgIn)
+	assert.Equal(t, uint64(20), v.PswpIn)
+	assert.Equal(t, uint64(1111), v.PgscanKswapd)
+	assert.Equal(t, uint64(7), v.AllocStall)
+	assert.Equal(t, uint64(77), v.WorkingsetRefault)
+	assert.Equal(t, uint64(0), v.ThpSwpout)
+	assert.Equal(t, uint64(2), v.OomKill)
+	_, ok := v.Raw["nr_dirty"]
+	assert.Equal(t, false, ok)
+
+	// 5.9+ splits workingset_refault by LRU type
+	v = parseVMStat([]string{"workingset_refault_anon 5", "workingset_refault_file 6", "thp_swpout 1", "thp_swpout_fallback 9"})
+	assert.Equal(t, uint64(11), v.WorkingsetRefault)
+	assert.Equal(t, uint64(1), v.ThpSwpout)
+}
+
+func TestVMStatRates(t *testing.T) {
+	prev := parseVMStat([]string{"pswpout 100", "pgscan_direct_normal 10", "nr_free_pages 500", "nr_dirty 10",
+		"nr_dirtied 1000", "nr_tlb_remote_flush 4", "workingset_nodes 80", "oom_kill 3"})
+	cur := parseVMStat([]string{"pswpout 300", "pgscan_direct_normal 30", "nr_free_pages 400", "nr_dirty 70",
+		"nr_dirtied 1100", "nr_tlb_remote_flush 10", "workingset_nodes 20", "oom_kill 1", "pgfault 1"})
+	cur.Timestamp = prev.Timestamp.Add(2 * time.Second)
+
+	rates, err := VMStatRates(prev, cur)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, map[string]float64{
+		"pswpout":              100,
+		"pgscan_direct_normal": 10,
+		"nr_dirtied":           50,
+		"nr_tlb_remote_flush":  3,
+		"oom_kill":             0, // went backwards
+	}, rates)
+
+	// gauges have no rate, whether they go up or down
+	assert.Equal(t, 0.0, cur.Rate(prev, "nr_dirty"))
+	assert.Equal(t, 0.0, cur.Rate(prev, "nr_free_pages"))
+	assert.Equal(t, 0.0, cur.Rate(prev, "workingset_nodes"))
+	assert.Equal(t, 50.0, cur.Rate(prev, "nr_dirtied"))
+
+	assert.Equal(t, 10.0, cur.Rate(prev, "pgscan_direct"))
+	assert.Equal(t, 0.0, cur.Rate(nil, "pswpout"))
+
+	_, err = VMStatRates(cur, prev)
+	assert.NotNil(t, err)
+}
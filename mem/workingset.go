@@ -0,0 +1,64 @@
+package mem
+
+import "encoding/json"
+
+// WorkingSetStat estimates the memory that cannot be reclaimed without
+// hurting the workload, the way the kubelet does, along with what the
+// kernel could reclaim under pressure. Values are in bytes.
+//
+// Usage is every page charged, page cache included: MemTotal - MemFree on a
+// host, memory.current or memory.usage_in_bytes in a cgroup. WorkingSet is
+// Usage minus the inactive file pages. It is the same computation for hosts
+// and containers, see CalculateWorkingSet.
+//
+// The reclaimable breakdown is made of CleanCache, the file pages that are
+// neither dirty nor under writeback, SlabReclaimable and SwappableAnon, the
+// inactive anonymous pages that fit in SwapFree. In a cgroup SwapFree is
+// bounded by its swap limit, which is 0 when swap is disabled for it.
+type WorkingSetStat struct {
+	Usage             uint64  `json:"usage"`
+	Limit             uint64  `json:"limit"`
+	WorkingSet        uint64  `json:"workingSet"`
+	WorkingSetPercent float64 `json:"workingSetPercent"`
+	ActiveFile        uint64  `json:"activeFile"`
+	InactiveFile      uint64  `json:"inactiveFile"`
+	ActiveAnon        uint64  `json:"activeAnon"`
+	InactiveAnon      uint64  `json:"inactiveAnon"`
+	Dirty             uint64  `json:"dirty"`
+	Writeback         uint64  `json:"writeback"`
+	SwapFree          uint64  `json:"swapFree"`
+	CleanCache        uint64  `json:"cleanCache"`
+	SlabReclaimable   uint64  `json:"slabReclaimable"`
+	SwappableAnon     uint64  `json:"swappableAnon"`
+	Reclaimable       uint64  `json:"reclaimable"`
+}
+
+func (w WorkingSetStat) String() string {
+	s, _ := json.Marshal(w)
+	return string(s)
+}
+
+// CalculateWorkingSet fills the derived fields of w, WorkingSet,
+// WorkingSetPercent, CleanCache, SwappableAnon and Reclaimable, from the
+// counters already set.
+func CalculateWorkingSet(w WorkingSetStat) WorkingSetStat {
+	w.WorkingSet = 0
+	if w.Usage > w.InactiveFile {
+		w.WorkingSet = w.Usage - w.InactiveFile
+	}
+	w.WorkingSetPercent = 0
+	if w.Limit > 0 {
+		w.WorkingSetPercent = float64(w.WorkingSet) / float64(w.Limit) * 100.0
+	}
+
+	w.CleanCache = 0
+	if file := w.ActiveFile + w.InactiveFile; file > w.Dirty+w.Writeback {
+		w.CleanCache = file - w.Dirty - w.Writeback
+	}
+	w.SwappableAnon = w.InactiveAnon
+	if w.SwappableAnon > w.SwapFree {
+		w.SwappableAnon = w.SwapFree
+	}
+	w.Reclaimable = w.CleanCache + w.SlabReclaimable + w.SwappableAnon
+	return w
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package mem
+
+import "context"
+
+// WorkingSet returns the working set estimate of the host and its
+// reclaimable memory breakdown.
+func WorkingSet() (*WorkingSetStat, error) {
+	return WorkingSetWithContext(context.Background())
+}
+
+func WorkingSetWithContext(ctx context.Context) (*WorkingSetStat, error) {
+	vm, vmEx, err := fillFromMeminfoWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := workingSetFromMeminfo(vm, vmEx)
+	return &ret, nil
+}
+
+func workingSetFromMeminfo(vm *VirtualMemoryStat, vmEx *ExVirtualMemory) WorkingSetStat {
+	w := WorkingSetStat{
+		Limit:           vm.Total,
+		ActiveFile:      vmEx.ActiveFile,
+		InactiveFile:    vmEx.InactiveFile,
+		ActiveAnon:      vmEx.ActiveAnon,
+		InactiveAnon:    vmEx.InactiveAnon,
+		Dirty:           vm.Dirty,
+		Writeback:       vm.WriteBack,
+		SwapFree:        vm.SwapFree,
+		SlabReclaimable: vm.Sreclaimable,
+	}
+	if vm.Total > vm.Free {
+		w.Usage = vm.Total - vm.Free
+	}
+	return CalculateWorkingSet(w)
+}
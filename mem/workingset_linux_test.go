@@ -0,0 +1,61 @@
+//go:build linux
+
+package mem
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+	"github.com/ravoni4devs/syspector/internal/test/fs"
+)
+
+func TestWorkingSetMeminfo(t *testing.T) {
+	proc := t.TempDir()
+	writeTestFile(t, filepath.Join(proc, "meminfo"), string(fs.ReadFromTestData("meminfo")))
+	t.Setenv("HOST_PROC", proc)
+
+	const (
+		total        = 32767112 * 1024
+		free         = 603012 * 1024
+		inactiveFile = 6815232 * 1024
+	)
+	ws, err := WorkingSet()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(total), ws.Limit)
+	assert.Equal(t, uint64(total-free), ws.Usage)
+	assert.Equal(t, uint64(total-free-inactiveFile), ws.WorkingSet)
+	// no free swap, inactive anon cannot be reclaimed
+	assert.Equal(t, uint64(0), ws.SwappableAnon)
+
+	// used has the meaning it has in a container
+	vm, err := GetStat()
+	assert.Nil(t, err)
+	assert.Equal(t, ws.WorkingSet, vm.Used)
+	assert.Equal(t, ws.WorkingSet, vm.WorkingSet)
+	assert.Equal(t, ws.WorkingSetPercent, vm.UsedPercent)
+}
+
+func TestCalculateWorkingSet(t *testing.T) {
+	w := CalculateWorkingSet(WorkingSetStat{
+		Usage:           1000,
+		Limit:           2000,
+		ActiveFile:      300,
+		InactiveFile:    200,
+		InactiveAnon:    150,
+		Dirty:           40,
+		Writeback:       10,
+		SwapFree:        100,
+		SlabReclaimable: 25,
+	})
+	assert.Equal(t, uint64(800), w.WorkingSet)
+	assert.Equal(t, 40.0, w.WorkingSetPercent)
+	assert.Equal(t, uint64(450), w.CleanCache)
+	assert.Equal(t, uint64(100), w.SwappableAnon)
+	assert.Equal(t, uint64(575), w.Reclaimable)
+
+	// inactive file above usage, as right after a cgroup moved
+	w = CalculateWorkingSet(WorkingSetStat{Usage: 100, InactiveFile: 200})
+	assert.Equal(t, uint64(0), w.WorkingSet)
+	assert.Equal(t, 0.0, w.WorkingSetPercent)
+}
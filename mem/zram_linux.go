@@ -0,0 +1,215 @@
+//go:build linux
+
+package mem
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ravoni4devs/syspector/internal/common"
+)
+
+// ZramDeviceStat holds the statistics of a zram block device, in bytes
+// unless noted otherwise.
+//
+// OrigDataSize is the uncompressed data stored in the device and
+// MemUsedTotal the RAM it really costs, allocator overhead included.
+// Saved is the difference between both and can be negative for badly
+// compressible data.
+type ZramDeviceStat struct {
+	Name             string  `json:"name"`
+	DiskSize         uint64  `json:"diskSize"`
+	Algorithm        string  `json:"algorithm"`
+	OrigDataSize     uint64  `json:"origDataSize"`
+	ComprDataSize    uint64  `json:"comprDataSize"`
+	MemUsedTotal     uint64  `json:"memUsedTotal"`
+	MemLimit         uint64  `json:"memLimit"`
+	MemUsedMax       uint64  `json:"memUsedMax"`
+	SamePages        uint64  `json:"samePages"`
+	PagesCompacted   uint64  `json:"pagesCompacted"`
+	HugePages        uint64  `json:"hugePages"`
+	FailedReads      uint64  `json:"failedReads"`
+	FailedWrites     uint64  `json:"failedWrites"`
+	InvalidIO        uint64  `json:"invalidIo"`
+	NotifyFree       uint64  `json:"notifyFree"`
+	CompressionRatio float64 `json:"compressionRatio"`
+	Saved            int64   `json:"saved"`
+}
+
+// ZswapStat holds the zswap configuration and pool statistics. Pool
+// counters come from /sys/kernel/debug/zswap, which requires debugfs and
+// root; without it PoolTotalSize and StoredBytes fall back to the Zswap
+// and Zswapped lines of /proc/meminfo (Linux 5.19+).
+type ZswapStat struct {
+	Enabled                bool    `json:"enabled"`
+	Compressor             string  `json:"compressor,omitempty"`
+	Zpool                  string  `json:"zpool,omitempty"`
+	MaxPoolPercent         uint64  `json:"maxPoolPercent"`
+	AcceptThresholdPercent uint64  `json:"acceptThresholdPercent,omitempty"`
+	PoolTotalSize          uint64  `json:"poolTotalSize"`
+	StoredBytes            uint64  `json:"storedBytes"`
+	StoredPages            uint64  `json:"storedPages"`
+	WrittenBackPages       uint64  `json:"writtenBackPages"`
+	SameFilledPages        uint64  `json:"sameFilledPages"`
+	PoolLimitHit           uint64  `json:"poolLimitHit"`
+	RejectCompressPoor     uint64  `json:"rejectCompressPoor"`
+	RejectCompressFail     uint64  `json:"rejectCompressFail"`
+	RejectAllocFail        uint64  `json:"rejectAllocFail"`
+	RejectReclaimFail      uint64  `json:"rejectReclaimFail"`
+	RejectKmemcacheFail    uint64  `json:"rejectKmemcacheFail"`
+	CompressionRatio       float64 `json:"compressionRatio"`
+	Saved                  int64   `json:"saved"`
+}
+
+func (z ZramDeviceStat) String() string {
+	s, _ := json.Marshal(z)
+	return string(s)
+}
+
+func (z ZswapStat) String() string {
+	s, _ := json.Marshal(z)
+	return string(s)
+}
+
+// ZramDevices returns the statistics of every zram device.
+func ZramDevices() ([]ZramDeviceStat, error) {
+	return ZramDevicesWithContext(context.Background())
+}
+
+func ZramDevicesWithContext(ctx context.Context) ([]ZramDeviceStat, error) {
+	dirs, err := filepath.Glob(common.HostSysWithContext(ctx, "block/zram[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []ZramDeviceStat
+	for _, dir := range dirs {
+		z := ZramDeviceStat{Name: filepath.Base(dir)}
+		z.DiskSize, _ = readSysUint(filepath.Join(dir, "disksize"))
+		z.Algorithm, _ = readSelectedMode(filepath.Join(dir, "comp_algorithm"))
+
+		// orig_data_size compr_data_size mem_used_total mem_limit mem_used_max
+		// same_pages pages_compacted huge_pages [huge_pages_since]
+		mm := readUintFields(filepath.Join(dir, "mm_stat"))
+		for i, field := range []*uint64{
+			&z.OrigDataSize, &z.ComprDataSize, &z.MemUsedTotal, &z.MemLimit, &z.MemUsedMax,
+			&z.SamePages, &z.PagesCompacted, &z.HugePages,
+		} {
+			if i < len(mm) {
+				*field = mm[i]
+			}
+		}
+
+		// failed_reads failed_writes invalid_io notify_free
+		io := readUintFields(filepath.Join(dir, "io_stat"))
+		for i, field := range []*uint64{&z.FailedReads, &z.FailedWrites, &z.InvalidIO, &z.NotifyFree} {
+			if i < len(io) {
+				*field = io[i]
+			}
+		}
+
+		if z.ComprDataSize > 0 {
+			z.CompressionRatio = float64(z.OrigDataSize) / float64(z.ComprDataSize)
+		}
+		z.Saved = int64(z.OrigDataSize) - int64(z.MemUsedTotal)
+		ret = append(ret, z)
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// Zswap returns the zswap configuration and pool statistics.
+func Zswap() (*ZswapStat, error) {
+	return ZswapWithContext(context.Background())
+}
+
+func ZswapWithContext(ctx context.Context) (*ZswapStat, error) {
+	params := common.HostSysWithContext(ctx, "module/zswap/parameters")
+	lines, err := common.ReadLines(filepath.Join(params, "enabled"))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &ZswapStat{}
+	ret.Enabled = len(lines) > 0 && strings.TrimSpace(lines[0]) == "Y"
+	if lines, err := common.ReadLines(filepath.Join(params, "compressor")); err == nil && len(lines) > 0 {
+		ret.Compressor = strings.TrimSpace(lines[0])
+	}
+	if lines, err := common.ReadLines(filepath.Join(params, "zpool")); err == nil && len(lines) > 0 {
+		ret.Zpool = strings.TrimSpace(lines[0])
+	}
+	ret.MaxPoolPercent, _ = readSysUint(filepath.Join(params, "max_pool_percent"))
+	ret.AcceptThresholdPercent, _ = readSysUint(filepath.Join(params, "accept_threshold_percent"))
+
+	pageSize := uint64(os.Getpagesize())
+	debug := common.HostSysWithContext(ctx, "kernel/debug/zswap")
+	if poolTotalSize, err := readSysUint(filepath.Join(debug, "pool_total_size")); err == nil {
+		ret.PoolTotalSize = poolTotalSize
+		ret.StoredPages, _ = readSysUint(filepath.Join(debug, "stored_pages"))
+		ret.WrittenBackPages, _ = readSysUint(filepath.Join(debug, "written_back_pages"))
+		ret.SameFilledPages, _ = readSysUint(filepath.Join(debug, "same_filled_pages"))
+		ret.PoolLimitHit, _ = readSysUint(filepath.Join(debug, "pool_limit_hit"))
+		ret.RejectCompressPoor, _ = readSysUint(filepath.Join(debug, "reject_compress_poor"))
+		ret.RejectCompressFail, _ = readSysUint(filepath.Join(debug, "reject_compress_fail"))
+		ret.RejectAllocFail, _ = readSysUint(filepath.Join(debug, "reject_alloc_fail"))
+		ret.RejectReclaimFail, _ = readSysUint(filepath.Join(debug, "reject_reclaim_fail"))
+		ret.RejectKmemcacheFail, _ = readSysUint(filepath.Join(debug, "reject_kmemcache_fail"))
+		ret.StoredBytes = ret.StoredPages * pageSize
+	} else {
+		meminfo := readMeminfoKeys(common.HostProcWithContext(ctx, "meminfo"), "Zswap", "Zswapped")
+		ret.PoolTotalSize = meminfo["Zswap"]
+		ret.StoredBytes = meminfo["Zswapped"]
+		ret.StoredPages = ret.StoredBytes / pageSize
+	}
+
+	if ret.PoolTotalSize > 0 {
+		ret.CompressionRatio = float64(ret.StoredBytes) / float64(ret.PoolTotalSize)
+	}
+	ret.Saved = int64(ret.StoredBytes) - int64(ret.PoolTotalSize)
+	return ret, nil
+}
+
+// readUintFields parses a single line file of space separated numbers,
+// such as zram mm_stat. Unreadable files yield nil.
+func readUintFields(filename string) []uint64 {
+	lines, err := common.ReadLines(filename)
+	if err != nil {
+		return nil
+	}
+	var ret []uint64
+	for _, field := range strings.Fields(strings.Join(lines, " ")) {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			break
+		}
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+// readMeminfoKeys returns the requested meminfo keys converted to bytes.
+func readMeminfoKeys(filename string, keys ...string) map[string]uint64 {
+	ret := make(map[string]uint64, len(keys))
+	lines, err := common.ReadLines(filename)
+	if err != nil {
+		return ret
+	}
+	for _, line := range lines {
+		key, value, found := strings.Cut(line, ":")
+		if !found || !common.StringsHas(keys, key) {
+			continue
+		}
+		v, err := strconv.ParseUint(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "kB")), 10, 64)
+		if err != nil {
+			continue
+		}
+		ret[key] = v * 1024
+	}
+	return ret
+}
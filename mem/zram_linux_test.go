@@ -0,0 +1,115 @@
+//go:build linux
+
+package mem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+)
+
+func TestZramDevices(t *testing.T) {
+	sys := t.TempDir()
+	t.Setenv("HOST_SYS", sys)
+	for name, content := range map[string]string{
+		// Linux 6.x, with the trailing huge_pages_since column
+		"block/zram0/disksize":       "8589934592\n",
+		"block/zram0/comp_algorithm": "lzo lzo-rle lz4 [zstd]\n",
+		"block/zram0/mm_stat":        "  4194304000  1048576000  1073741824        0  1200000000    12345      100     7     9\n",
+		"block/zram0/io_stat":        "       1        2        3     4567\n",
+		// Linux 4.x, without huge_pages
+		"block/zram1/disksize":       "1073741824\n",
+		"block/zram1/comp_algorithm": "[lzo] lz4\n",
+		"block/zram1/mm_stat":        "4096 8192 16384 0 16384 0 0\n",
+	} {
+		writeTestFile(t, filepath.Join(sys, name), content)
+	}
+
+	devices, err := ZramDevices()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(devices))
+
+	assert.DeepEqual(t, ZramDeviceStat{
+		Name:             "zram0",
+		DiskSize:         8 << 30,
+		Algorithm:        "zstd",
+		OrigDataSize:     4194304000,
+		ComprDataSize:    1048576000,
+		MemUsedTotal:     1 << 30,
+		MemUsedMax:       1200000000,
+		SamePages:        12345,
+		PagesCompacted:   100,
+		HugePages:        7,
+		FailedReads:      1,
+		FailedWrites:     2,
+		InvalidIO:        3,
+		NotifyFree:       4567,
+		CompressionRatio: 4,
+		Saved:            4194304000 - 1<<30,
+	}, devices[0])
+
+	// incompressible data costs more than it stores
+	z := devices[1]
+	assert.Equal(t, "lzo", z.Algorithm)
+	assert.Equal(t, uint64(0), z.HugePages)
+	assert.Equal(t, 0.5, z.CompressionRatio)
+	assert.Equal(t, int64(4096-16384), z.Saved)
+}
+
+func TestZswapDebugfs(t *testing.T) {
+	sys := t.TempDir()
+	t.Setenv("HOST_SYS", sys)
+	pageSize := uint64(os.Getpagesize())
+	for name, content := range map[string]string{
+		"module/zswap/parameters/enabled":                  "Y\n",
+		"module/zswap/parameters/compressor":               "zstd\n",
+		"module/zswap/parameters/zpool":                    "zsmalloc\n",
+		"module/zswap/parameters/max_pool_percent":         "20\n",
+		"module/zswap/parameters/accept_threshold_percent": "90\n",
+		"kernel/debug/zswap/pool_total_size":               "1048576\n",
+		"kernel/debug/zswap/stored_pages":                  "1024\n",
+		"kernel/debug/zswap/written_back_pages":            "5\n",
+		"kernel/debug/zswap/reject_compress_poor":          "2\n",
+	} {
+		writeTestFile(t, filepath.Join(sys, name), content)
+	}
+
+	z, err := Zswap()
+	assert.Nil(t, err)
+	assert.Equal(t, true, z.Enabled)
+	assert.Equal(t, "zstd", z.Compressor)
+	assert.Equal(t, "zsmalloc", z.Zpool)
+	assert.Equal(t, uint64(20), z.MaxPoolPercent)
+	assert.Equal(t, uint64(90), z.AcceptThresholdPercent)
+	assert.Equal(t, uint64(1024), z.StoredPages)
+	assert.Equal(t, 1024*pageSize, z.StoredBytes)
+	assert.Equal(t, uint64(5), z.WrittenBackPages)
+	assert.Equal(t, uint64(2), z.RejectCompressPoor)
+	assert.Equal(t, float64(1024*pageSize)/1048576, z.CompressionRatio)
+	assert.Equal(t, int64(1024*pageSize)-1048576, z.Saved)
+}
+
+func TestZswapMeminfo(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOST_SYS", filepath.Join(root, "sys"))
+	t.Setenv("HOST_PROC", filepath.Join(root, "proc"))
+	writeTestFile(t, filepath.Join(root, "sys", "module/zswap/parameters/enabled"), "N\n")
+	writeTestFile(t, filepath.Join(root, "proc", "meminfo"),
+		"MemTotal:        8388608 kB\nMemFree:         1048576 kB\nMemAvailable:    4194304 kB\nZswap:             10240 kB\nZswapped:          40960 kB\n")
+
+	// no debugfs, the pool size comes from meminfo
+	z, err := Zswap()
+	assert.Nil(t, err)
+	assert.Equal(t, false, z.Enabled)
+	assert.Equal(t, uint64(10240*1024), z.PoolTotalSize)
+	assert.Equal(t, uint64(40960*1024), z.StoredBytes)
+	assert.Equal(t, 4.0, z.CompressionRatio)
+	assert.Equal(t, int64(30720*1024), z.Saved)
+
+	// zswap is not built in
+	t.Setenv("HOST_SYS", t.TempDir())
+	_, err = Zswap()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,123 @@
+//go:build linux
+
+package pid
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ravoni4devs/syspector/internal/test/assert"
+	"github.com/ravoni4devs/syspector/internal/test/fs"
+)
+
+func TestParseSmaps(t *testing.T) {
+	f, err := os.Open(fs.TestDataJoin("smaps"))
+	assert.Nil(t, err)
+	defer f.Close()
+
+	mappings, err := parseSmaps(f)
+	assert.Nil(t, err)
+	assert.Equal(t, 3227, len(mappings))
+
+	total := sumSmaps(mappings)
+	assert.Equal(t, uint64(757068*1024), total.Rss)
+	assert.Equal(t, uint64(545531*1024), total.Pss)
+	assert.Equal(t, uint64((5488+514948)*1024), total.Uss)
+	assert.Equal(t, uint64(235496*1024), total.SharedClean)
+	assert.Equal(t, uint64(1136*1024), total.SharedDirty)
+	assert.Equal(t, uint64(505464*1024), total.Anonymous)
+	assert.Equal(t, uint64(0), total.Swap)
+
+	split := total.PssAnon + total.PssFile + total.PssShmem
+	assert.Equal(t, total.Pss, split)
+	if total.PssAnon < total.Anonymous*9/10 || total.PssAnon > total.Anonymous {
+		t.Fatalf("PssAnon %d is not close to Anonymous %d", total.PssAnon, total.Anonymous)
+	}
+}
+
+func TestParseSmapsSplit(t *testing.T) {
+	const smaps = `7f0000000000-7f0000010000 rw-p 00001000 08:01 1234    /usr/lib/libfoo.so
+Rss:                  64 kB
+Pss:                  32 kB
+Private_Dirty:        16 kB
+Shared_Clean:         48 kB
+Anonymous:            16 kB
+7f0000010000-7f0000020000 rw-s 00000000 00:05 42      /dev/shm/queue
+Rss:                  64 kB
+Pss:                  64 kB
+Private_Dirty:        64 kB
+7ffc00000000-7ffc00021000 rw-p 00000000 00:00 0       [stack]
+Rss:                  12 kB
+Pss:                  12 kB
+Private_Dirty:        12 kB
+Anonymous:            12 kB
+`
+	mappings, err := parseSmaps(strings.NewReader(smaps))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(mappings))
+
+	// copy-on-write pages of a private file mapping are anonymous
+	lib := mappings[0]
+	assert.Equal(t, uint64(8*1024), lib.PssAnon)
+	assert.Equal(t, uint64(24*1024), lib.PssFile)
+	assert.Equal(t, uint64(16*1024), lib.Uss)
+
+	shm := mappings[1]
+	assert.Equal(t, uint64(64*1024), shm.PssShmem)
+	assert.Equal(t, uint64(0), shm.PssAnon+shm.PssFile)
+
+	stack := mappings[2]
+	assert.Equal(t, "[stack]", stack.Path)
+	assert.Equal(t, uint64(12*1024), stack.PssAnon)
+	assert.Equal(t, uint64(0), stack.PssFile)
+}
+
+func TestParseSmapsRollup(t *testing.T) {
+	const before510 = `00400000-7ffc3a5f1000 ---p 00000000 00:00 0                          [rollup]
+Rss:                8000 kB
+Pss:                6000 kB
+Anonymous:          4000 kB
+`
+	mappings, err := parseSmaps(strings.NewReader(before510))
+	assert.Nil(t, err)
+	total := sumSmaps(mappings)
+	assert.Equal(t, uint64(6000*1024), total.Pss)
+	assert.Equal(t, uint64(0), total.PssAnon+total.PssFile+total.PssShmem)
+
+	const after510 = before510 + `Pss_Anon:           3500 kB
+Pss_File:           2000 kB
+Pss_Shmem:           500 kB
+`
+	mappings, err = parseSmaps(strings.NewReader(after510))
+	assert.Nil(t, err)
+	total = sumSmaps(mappings)
+	assert.Equal(t, uint64(3500*1024), total.PssAnon)
+	assert.Equal(t, uint64(2000*1024), total.PssFile)
+	assert.Equal(t, uint64(500*1024), total.PssShmem)
+}
+
+func TestSmapsByFile(t *testing.T) {
+	proc := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(proc, "42"), 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(proc, "42", "smaps"), fs.ReadFromTestData("smaps"), 0o644))
+	t.Setenv("HOST_PROC", proc)
+
+	files, err := SmapsByFile(42)
+	assert.Nil(t, err)
+
+	var mappings int
+	for i, f := range files {
+		mappings += f.Mappings
+		if i > 0 && f.Pss > files[i-1].Pss {
+			t.Fatalf("%s is not sorted by Pss", f.Path)
+		}
+	}
+	assert.Equal(t, 3227, mappings)
+
+	// without smaps_rollup the totals are summed from smaps
+	stat, err := Smaps(42)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(545531*1024), stat.Pss)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/ravoni4devs/syspector/cpu"
 	"github.com/ravoni4devs/syspector/docker"
 	"github.com/ravoni4devs/syspector/goruntime"
+	"github.com/ravoni4devs/syspector/internal/common"
 	"github.com/ravoni4devs/syspector/mem"
 	"github.com/ravoni4devs/syspector/pid"
 	"github.com/ravoni4devs/syspector/system"
@@ -26,13 +27,18 @@ func New() Collector {
 	return &statCollector{}
 }
 
+// Stats holds the usage of the container the process runs in, or of the
+// host outside containers. CpuPercent is relative to EffectiveCPUs in both
+// cases, the CPUs the container may use or those of the host, and is within
+// 0-100.
 type Stats struct {
-	Memory     mem.VirtualMemoryStat `json:"memory"`
-	CpuPercent float64               `json:"cpu"`
-	CPUs       []cpu.InfoStat        `json:"cpus,omitzero"`
-	Runtime    goruntime.RuntimeStat `json:"runtime"`
-	PID        pid.PidStat           `json:"pid"`
-	System     system.SystemStat     `json:"system"`
+	Memory        mem.VirtualMemoryStat `json:"memory"`
+	CpuPercent    float64               `json:"cpu"`
+	EffectiveCPUs float64               `json:"effectiveCpus"`
+	CPUs          []cpu.InfoStat        `json:"cpus,omitzero"`
+	Runtime       goruntime.RuntimeStat `json:"runtime"`
+	PID           pid.PidStat           `json:"pid"`
+	System        system.SystemStat     `json:"system"`
 }
 
 func (c *statCollector) Stats() (Stats, error) {
@@ -50,15 +56,21 @@ func (c *statCollector) Stats() (Stats, error) {
 	}
 	stats.PID = pidStat
 
-	dockerStat, err := docker.GetStat(time.Second * 1)
-	if err == nil {
-		stats.CpuPercent = dockerStat.CpuPercent
-		stats.Memory.Total = dockerStat.Memory.Total
-		stats.Memory.Available = dockerStat.Memory.Available
-		stats.Memory.Free = dockerStat.Memory.Free
-		stats.Memory.Used = dockerStat.Memory.Used
-		stats.Memory.UsedPercent = dockerStat.Memory.UsedPercent
-		return stats, nil
+	// the cgroup of an unconfined host process is a systemd slice, whose
+	// usage is not that of the process
+	if containerized, _ := docker.Containerized(int32(os.Getpid())); containerized {
+		dockerStat, err := docker.GetStat(time.Second * 1)
+		if err == nil {
+			stats.CpuPercent = common.Round(dockerStat.CPUQuota.QuotaPercent, 2)
+			stats.EffectiveCPUs = dockerStat.CPUQuota.EffectiveCPUs
+			stats.Memory.Total = dockerStat.Memory.Total
+			stats.Memory.Available = dockerStat.Memory.Available
+			stats.Memory.Free = dockerStat.Memory.Free
+			stats.Memory.Used = dockerStat.Memory.Used
+			stats.Memory.UsedPercent = dockerStat.Memory.UsedPercent
+			stats.Memory.WorkingSet = dockerStat.Memory.WorkingSet
+			return stats, nil
+		}
 	}
 	memoryStat, err := mem.GetStat()
 	if err != nil {
@@ -69,6 +81,8 @@ func (c *statCollector) Stats() (Stats, error) {
 	stats.Memory.Free = memoryStat.Free
 	stats.Memory.Used = memoryStat.Used
 	stats.Memory.UsedPercent = memoryStat.UsedPercent
+	stats.Memory.WorkingSet = memoryStat.WorkingSet
+	stats.EffectiveCPUs = float64(systemStat.CPUs)
 	cpuPercent, err := cpu.Percent(time.Second*1, false)
 	if err != nil || len(cpuPercent) == 0 {
 		return stats, fmt.Errorf("getting cpu percent %s", err)
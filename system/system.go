@@ -59,3 +59,12 @@ func GetOSInfo() (SystemStat, error) {
 func NumCPU() int {
 	return runtime.NumCPU()
 }
+
+// IsVirtualized reports whether the host runs under a hypervisor. It is
+// the value of SystemStat.Virtualized and is always false outside Linux.
+func IsVirtualized() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	return isVirtualized()
+}